@@ -0,0 +1,97 @@
+package jbot
+
+import (
+	"context"
+	"sync"
+)
+
+// cachedChats is the number of chats whose settings are cached.
+const cachedChats = 10000
+
+// chatCache caches a setting of each chat that is loaded from the
+// database. The database is queried without holding the lock, and the
+// lookups of a chat that is being loaded wait for the same query. The
+// chat that was cached first is forgotten when the cache is full.
+type chatCache struct {
+	size int // max number of chats
+	load func(ctx context.Context, chatID int64) (interface{}, error)
+
+	mu      sync.Mutex
+	values  map[int64]interface{}
+	order   []int64 // the chats of values in the order they were added
+	loading map[int64]*chatLoad
+}
+
+// chatLoad is a query for the setting of a chat that is in progress.
+type chatLoad struct {
+	done  chan struct{} // closed when the query is done
+	value interface{}
+	err   error
+	stale bool // true if the setting was changed during the query
+}
+
+func newChatCache(size int, load func(ctx context.Context, chatID int64) (interface{}, error)) *chatCache {
+	return &chatCache{
+		size:    size,
+		load:    load,
+		values:  make(map[int64]interface{}),
+		loading: make(map[int64]*chatLoad),
+	}
+}
+
+// get returns the setting of chatID, loading it the first time.
+func (c *chatCache) get(ctx context.Context, chatID int64) (interface{}, error) {
+	c.mu.Lock()
+	if value, ok := c.values[chatID]; ok {
+		c.mu.Unlock()
+		return value, nil
+	}
+	if l, ok := c.loading[chatID]; ok {
+		c.mu.Unlock()
+		select {
+		case <-l.done:
+			return l.value, l.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	l := &chatLoad{done: make(chan struct{})}
+	c.loading[chatID] = l
+	c.mu.Unlock()
+
+	l.value, l.err = c.load(ctx, chatID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.loading, chatID)
+	if l.err == nil && !l.stale {
+		c.store(chatID, l.value)
+	}
+	close(l.done)
+	return l.value, l.err
+}
+
+// put sets the setting of chatID after it was changed in the database.
+// A query of chatID that is in progress does not replace it.
+func (c *chatCache) put(chatID int64, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l, ok := c.loading[chatID]; ok {
+		l.stale = true
+	}
+	c.store(chatID, value)
+}
+
+// store caches value for chatID. c.mu must be held.
+func (c *chatCache) store(chatID int64, value interface{}) {
+	if _, ok := c.values[chatID]; !ok {
+		c.order = append(c.order, chatID)
+		if len(c.order) > c.size {
+			delete(c.values, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	c.values[chatID] = value
+}
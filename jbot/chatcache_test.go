@@ -0,0 +1,83 @@
+package jbot
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChatCacheSharesLoads(t *testing.T) {
+	var mu sync.Mutex
+	loads := 0
+	release := make(chan struct{})
+	c := newChatCache(10, func(ctx context.Context, chatID int64) (interface{}, error) {
+		mu.Lock()
+		loads++
+		mu.Unlock()
+		<-release
+		return "fi", nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := c.get(context.Background(), 1); err != nil || value != "fi" {
+				t.Errorf("unexpected value %v, %v", value, err)
+			}
+		}()
+	}
+
+	// another chat is not blocked by the load in progress
+	c.put(2, "en")
+	if value, err := c.get(context.Background(), 2); err != nil || value != "en" {
+		t.Errorf("unexpected value of the other chat %v, %v", value, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if loads != 1 {
+		t.Errorf("the chat was loaded %v times", loads)
+	}
+}
+
+func TestChatCacheKeepsChangesDuringLoad(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	c := newChatCache(10, func(ctx context.Context, chatID int64) (interface{}, error) {
+		close(started)
+		<-release
+		return "old", nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		c.get(context.Background(), 1)
+		close(done)
+	}()
+	<-started
+	c.put(1, "new")
+	close(release)
+	<-done
+
+	if value, _ := c.get(context.Background(), 1); value != "new" {
+		t.Errorf("the change was replaced by the load: %v", value)
+	}
+}
+
+func TestChatCacheForgetsOldestChat(t *testing.T) {
+	loads := 0
+	c := newChatCache(2, func(ctx context.Context, chatID int64) (interface{}, error) {
+		loads++
+		return chatID, nil
+	})
+
+	for _, chatID := range []int64{1, 2, 3, 1} {
+		c.get(context.Background(), chatID)
+	}
+	if loads != 4 || len(c.values) != 2 {
+		t.Errorf("expected 4 loads and 2 cached chats, got %v and %v", loads, len(c.values))
+	}
+}
@@ -0,0 +1,161 @@
+package jbot
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Command is a command at the start of a message,
+// such as "/decide@juhannusbot sauna "go swimming"".
+type Command struct {
+	Name    string   // the command in lower case without the mention, e.g. "/decide"
+	Mention string   // the bot the command was addressed to, empty if none
+	Args    []string // the words after the command, quoted words form one argument
+}
+
+// Commander can be implemented by features that own commands.
+// Commands returns the commands of the feature, such as "/decide".
+// A message that starts with one of them is routed to the feature
+// without calling Triggers, and Update.Command holds the parsed command.
+// If features share a command, the one with the highest priority owns it.
+type Commander interface {
+	Commands() []string
+}
+
+// parseCommand splits text into a command and its arguments.
+// It returns false if text is empty.
+func parseCommand(text string) (Command, bool) {
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	end := strings.IndexFunc(text, unicode.IsSpace)
+	if end < 0 {
+		end = len(text)
+	}
+	if end == 0 {
+		return Command{}, false
+	}
+
+	cmd := Command{Name: strings.ToLower(text[:end])}
+	if at := strings.Index(cmd.Name, "@"); at > 0 {
+		cmd.Name, cmd.Mention = cmd.Name[:at], cmd.Name[at+1:]
+	}
+	cmd.Args = splitArgs(text[end:])
+	return cmd, true
+}
+
+// closingQuotes maps the quotes that can start a quoted
+// argument to the quotes that end it.
+var closingQuotes = map[rune]rune{
+	'"':  '"',
+	'\'': '\'',
+	'“':  '”',
+	'”':  '”',
+	'«':  '»',
+}
+
+// splitArgs splits s into words separated by whitespace. A quote at the
+// start of a word makes everything until the closing quote one argument,
+// so quotes inside words, like in "don't", are kept as they are.
+// An argument with no closing quote extends to the end of s.
+func splitArgs(s string) []string {
+	args := []string{}
+
+	var current strings.Builder
+	inWord := false
+	var closing rune // the quote that ends the current argument, 0 if not quoted
+
+	for _, c := range s {
+		switch {
+		case closing != 0:
+			if c == closing {
+				closing = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case unicode.IsSpace(c):
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		case !inWord && closingQuotes[c] != 0:
+			closing = closingQuotes[c]
+			inWord = true
+		default:
+			current.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, current.String())
+	}
+
+	return args
+}
+
+// commandTable maps the commands of the features to the features that own them.
+type commandTable map[string]Feature
+
+// newCommandTable returns the commands of features, which
+// are sorted from the highest priority to the lowest.
+func newCommandTable(features []Feature) commandTable {
+	table := make(commandTable)
+	for _, feat := range features {
+		commander, ok := commanderOf(feat)
+		if !ok {
+			continue
+		}
+		for _, name := range commander.Commands() {
+			name = strings.ToLower(name)
+			if _, taken := table[name]; !taken {
+				table[name] = feat
+			}
+		}
+	}
+	return table
+}
+
+// route parses the command at the start of u and returns the feature
+// that owns it. Commands addressed to other bots are ignored.
+// botName is the username of the bot, or empty if it is not known.
+func (table commandTable) route(u Update, botName string) (*Command, Feature) {
+	if u.Message == nil {
+		return nil, nil
+	}
+
+	cmd, ok := parseCommand(u.Message.Text)
+	if !ok {
+		return nil, nil
+	}
+	if cmd.Mention != "" && botName != "" && cmd.Mention != strings.ToLower(botName) {
+		return nil, nil
+	}
+
+	feat, ok := table[cmd.Name]
+	if !ok {
+		return nil, nil
+	}
+	return &cmd, feat
+}
+
+// commanderOf returns the Commander of feat, looking through middlewares.
+func commanderOf(feat Feature) (Commander, bool) {
+	for _, f := range featureChain(feat) {
+		if commander, ok := f.(Commander); ok {
+			return commander, true
+		}
+	}
+	return nil, false
+}
+
+// isCommand returns true if u has a command that is one of names.
+func isCommand(u Update, names []string) bool {
+	if u.Command == nil {
+		return false
+	}
+	for _, name := range names {
+		if u.Command.Name == strings.ToLower(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package jbot
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text string
+		want Command
+	}{
+		{"/decide", Command{Name: "/decide", Args: []string{}}},
+		{"/Decide@JuhannusBot sauna  lake", Command{Name: "/decide", Mention: "juhannusbot", Args: []string{"sauna", "lake"}}},
+		{`/decide "go swimming" 'stay inside' sauna`, Command{Name: "/decide", Args: []string{"go swimming", "stay inside", "sauna"}}},
+		{"/decide don't go", Command{Name: "/decide", Args: []string{"don't", "go"}}},
+		{"/decide “smart quotes” ”finnish quotes”", Command{Name: "/decide", Args: []string{"smart quotes", "finnish quotes"}}},
+		{`/decide "never closed`, Command{Name: "/decide", Args: []string{"never closed"}}},
+		{`/decide ""`, Command{Name: "/decide", Args: []string{""}}},
+	}
+
+	for _, test := range tests {
+		got, ok := parseCommand(test.text)
+		if !ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseCommand(%q) = %#v, expected %#v", test.text, got, test.want)
+		}
+	}
+
+	if _, ok := parseCommand("   "); ok {
+		t.Error("an empty message was parsed as a command")
+	}
+}
+
+func TestCommandTableRoute(t *testing.T) {
+	d := &decide{triggerWords: []string{"/decide", "/choose"}}
+	table := newCommandTable([]Feature{withLogging(d), &fakeFeature{name: "other"}})
+
+	if cmd, owner := table.route(textUpdate("/CHOOSE@juhannusbot a b"), "JuhannusBot"); owner == nil || cmd.Name != "/choose" {
+		t.Errorf("an alias addressed to the bot was not routed: %v, %v", cmd, owner)
+	}
+	if _, owner := table.route(textUpdate("/decide@otherbot a b"), "juhannusbot"); owner != nil {
+		t.Error("a command addressed to another bot was routed")
+	}
+	if _, owner := table.route(textUpdate("/decidenow a b"), "juhannusbot"); owner != nil {
+		t.Error("a longer command was routed to the feature of its prefix")
+	}
+}
+
+func TestDispatchRoutesCommands(t *testing.T) {
+	m := &recordingMessenger{}
+	d := &decide{triggerWords: []string{"/decide"}}
+	catchAll := &fakeFeature{name: "catch_all", trigger: true, handle: true}
+	features := []Feature{catchAll, d}
+	bot := &Bot{messenger: m, features: features, commands: newCommandTable(features)}
+
+	dispatch(context.Background(), bot, textUpdate(`/decide "sauna now" "lake later"`))
+	if len(catchAll.executed) != 0 {
+		t.Error("a command was not routed to its owner first")
+	}
+	if len(m.replies) != 1 || (m.replies[0].Text != "sauna now" && m.replies[0].Text != "lake later") {
+		t.Errorf("decide did not reply with a quoted option: %+v", m.replies)
+	}
+
+	dispatch(context.Background(), bot, textUpdate("/decidenow sauna lake"))
+	if len(catchAll.executed) != 1 || len(m.replies) != 1 {
+		t.Error("an unknown command was routed to decide")
+	}
+}
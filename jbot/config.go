@@ -8,41 +8,85 @@ import (
 
 const configFileName = "config.json"
 
-// config holds the configuration data for jbot.
-type config struct {
+// Config holds the configuration data for jbot.
+type Config struct {
 	APIKey      string          `json:"apikey"`
 	DatabaseURL string          `json:"databaseurl"`
 	Features    json.RawMessage `json:"features"`
+
+	Workers       int `json:"workers"`       // number of updates handled in parallel
+	QueueSize     int `json:"queuesize"`     // max number of updates waiting to be handled
+	ChatQueueSize int `json:"chatqueuesize"` // max number of updates waiting per chat
+
+	ShutdownTimeout int `json:"shutdowntimeout"` // seconds to wait for features to finish when stopping
+	RetryInterval   int `json:"retryinterval"`   // seconds between attempts to start features that are not running
+
+	Webhook    WebhookConfig   `json:"webhook"`
+	RateLimit  RateLimitConfig `json:"ratelimit"`
+	OffsetFile string          `json:"offsetfile"` // keeps the last handled update if the database has no update_offset table
+
+	Priorities map[string]int `json:"priorities"` // features with higher priorities run first, default 0
+	ErrorReply string         `json:"errorreply"` // if set, sent to the chat when a feature fails
+	PanicLimit int            `json:"paniclimit"` // panics in a row that disable a feature, default 3, -1 for never
+
+	Middleware map[string][]string `json:"middleware"` // middlewares of each feature, "*" applies to all
+
+	CommandMenu []CommandMenuConfig `json:"commandmenu"` // command lists shown in telegram, by default one with every command
+
+	EditedCommands bool `json:"editedcommands"` // if true, edited commands run again and edit their replies
+
+	Language string `json:"language"` // language of the replies when neither the chat nor the user has one, default "en"
+	Locales  string `json:"locales"`  // directory of extra translations, default none
+
+	Log LogConfig `json:"log"` // format of the log and levels of the lines that are written
+
+	Timezone string `json:"timezone"` // time zone of the scheduled jobs that have none, default the time zone of the computer
 }
 
 // configure reads config.json to a config struct.
-func configure() (config, error) {
+func configure() (Config, error) {
 	return configureFromFile(configFileName)
 }
 
-func configureFromFile(fileName string) (config, error) {
-	rawBytes, err := ioutil.ReadFile(fileName)
-	if err != nil {
-		errorMessage := "Failed to open \"" + fileName +
-			"\". Check that your current working directory has " +
-			"a file called \"" + fileName + "\"."
-		return config{}, errors.New(errorMessage)
-	}
-
-	var cfg config
-	err = json.Unmarshal(rawBytes, &cfg)
+func configureFromFile(fileName string) (Config, error) {
+	cfg, err := LoadConfig(fileName)
 	if err != nil {
-		return config{}, err
+		return Config{}, err
 	}
 
 	if cfg.APIKey == "" {
 		err = errors.New("Could not find apikey in " + fileName)
-		return config{}, err
+		return Config{}, err
 	}
 
 	if cfg.DatabaseURL == "" {
 		err = errors.New("Could not find databaseurl in" + fileName)
-		return config{}, err
+		return Config{}, err
+	}
+
+	if cfg.Webhook.Enabled && cfg.Webhook.URL == "" {
+		err = errors.New("Could not find webhook url in " + fileName)
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// LoadConfig reads a config file such as config.json without checking
+// that the required fields are set. New checks them instead.
+func LoadConfig(fileName string) (Config, error) {
+	rawBytes, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		errorMessage := "Failed to open \"" + fileName +
+			"\". Check that your current working directory has " +
+			"a file called \"" + fileName + "\"."
+		return Config{}, errors.New(errorMessage)
+	}
+
+	var cfg Config
+	err = json.Unmarshal(rawBytes, &cfg)
+	if err != nil {
+		return Config{}, err
 	}
 
 	return cfg, nil
@@ -6,7 +6,7 @@ import (
 
 // configsAreSimilar returns true if configs a and b
 // share the same APIKey and DatabaseURL.
-func configsAreSimilar(a config, b config) bool {
+func configsAreSimilar(a Config, b Config) bool {
 	return a.APIKey == b.APIKey && a.DatabaseURL == b.DatabaseURL
 }
 
@@ -16,7 +16,7 @@ func TestConfigureFromWorkingFile(t *testing.T) {
 		t.Error(err)
 	}
 
-	expectedResult := config{
+	expectedResult := Config{
 		APIKey:      "TestKey123",
 		DatabaseURL: "Poirot",
 		Features:    []byte("some raw bytes"),
@@ -54,7 +54,7 @@ func TestConfigureFromModifiedFile(t *testing.T) {
 		t.Fail()
 	}
 
-	expectedResult := config{
+	expectedResult := Config{
 		APIKey:      "TestKey123",
 		DatabaseURL: "Poirot",
 		Features:    []byte("some raw bytes"),
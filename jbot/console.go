@@ -0,0 +1,220 @@
+package jbot
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	consoleChatID = 1
+	consoleUserID = 1
+)
+
+// console is a messenger that lets a user chat with the bot in a terminal.
+// Inline keyboards are printed as rows of [buttons] and a button
+// is pressed by typing it in brackets, e.g. "[♒]".
+type console struct {
+	in  io.Reader
+	out io.Writer
+
+	mu              sync.Mutex
+	lastMessageID   int
+	lastUserMessage int                // ID of the last message typed by the user
+	keyboards       map[int][][]Button // keyboards of the sent messages by message ID
+	lastKeyboard    int                // ID of the last message that had a keyboard
+}
+
+func newConsole(in io.Reader, out io.Writer) *console {
+	return &console{
+		in:        in,
+		out:       out,
+		keyboards: make(map[int][][]Button),
+	}
+}
+
+func (c *console) send(ctx context.Context, r Reply) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	messageID := r.EditMessageID
+	if messageID == 0 {
+		c.lastMessageID++
+		messageID = c.lastMessageID
+	}
+	if len(r.Keyboard) > 0 {
+		c.keyboards[messageID] = r.Keyboard
+		c.lastKeyboard = messageID
+	}
+
+	_, err := io.WriteString(c.out, renderConsoleReply(r))
+	return messageID, err
+}
+
+func (c *console) answerCallback(ctx context.Context, callbackID string, text string) error {
+	if text == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := fmt.Fprintf(c.out, "bot (popup): %v\n", text)
+	return err
+}
+
+// answerInline prints the results of an inline query.
+func (c *console) answerInline(ctx context.Context, queryID string, answer InlineAnswer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(answer.Results) == 0 {
+		_, err := fmt.Fprintln(c.out, "bot (inline): no results")
+		return err
+	}
+	for _, result := range answer.Results {
+		if _, err := fmt.Fprintf(c.out, "bot (inline): %v – %v\n    %v\n", result.Title, result.Description, result.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isAdmin returns true because the console user owns the console chat.
+func (c *console) isAdmin(ctx context.Context, chatID int64, userID int64) (bool, error) {
+	return true, nil
+}
+
+// receive reads lines from the console and passes them to submit
+// as updates until the input ends or ctx is done.
+func (c *console) receive(ctx context.Context, submit func(Update) bool) error {
+
+	fmt.Fprintln(c.out, "Type messages to the bot. Press a button by typing it in brackets, e.g. [♒]. Edit your last message by starting a line with *. Start a line with @ for an inline query. End with Ctrl+D.")
+
+	lines := make(chan string)
+	scanErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(c.in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr <- scanner.Err()
+	}()
+
+	for updateID := 1; ; updateID++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-scanErr:
+			return err
+		case line := <-lines:
+			submit(c.parseLine(updateID, line))
+		}
+	}
+}
+
+// parseLine turns a line typed to the console into an update. A line that
+// names a button of the last keyboard in brackets becomes a callback,
+// a line that starts with * edits the last message, a line that starts
+// with @ is an inline query and everything else becomes a message.
+func (c *console) parseLine(updateID int, line string) Update {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sender := &User{ID: consoleUserID, FirstName: "console"}
+
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+		text := strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+		for _, row := range c.keyboards[c.lastKeyboard] {
+			for _, b := range row {
+				if b.Text == text {
+					return Update{
+						ID:   updateID,
+						Kind: KindCallback,
+						Callback: &Callback{
+							ID:      fmt.Sprint(updateID),
+							Data:    b.Data,
+							Sender:  sender,
+							Message: &Message{ID: c.lastKeyboard, Chat: consoleChat()},
+						},
+					}
+				}
+			}
+		}
+	}
+
+	if strings.HasPrefix(line, "@") {
+		return Update{
+			ID:     updateID,
+			Kind:   KindInlineQuery,
+			Inline: &Inline{ID: fmt.Sprint(updateID), Query: strings.TrimPrefix(line, "@"), Sender: sender},
+		}
+	}
+
+	if strings.HasPrefix(line, "*") && c.lastUserMessage != 0 {
+		return Update{
+			ID:   updateID,
+			Kind: KindEditedMessage,
+			Message: &Message{
+				ID:     c.lastUserMessage,
+				Text:   strings.TrimPrefix(line, "*"),
+				Sender: sender,
+				Chat:   consoleChat(),
+			},
+		}
+	}
+
+	c.lastMessageID++
+	c.lastUserMessage = c.lastMessageID
+	return Update{
+		ID:   updateID,
+		Kind: KindMessage,
+		Message: &Message{
+			ID:     c.lastMessageID,
+			Text:   line,
+			Sender: sender,
+			Chat:   consoleChat(),
+		},
+	}
+}
+
+func consoleChat() Chat {
+	return Chat{ID: consoleChatID, Type: "private", Title: "console"}
+}
+
+// renderConsoleReply renders r as text, with its keyboard
+// drawn as rows of [buttons] below it.
+func renderConsoleReply(r Reply) string {
+	var b strings.Builder
+
+	b.WriteString("bot")
+	if r.ReplyToMessageID != 0 {
+		fmt.Fprintf(&b, " (reply to #%v)", r.ReplyToMessageID)
+	}
+	if r.EditMessageID != 0 {
+		fmt.Fprintf(&b, " (edited #%v)", r.EditMessageID)
+	}
+	b.WriteString(": ")
+	b.WriteString(r.Text)
+	b.WriteString("\n")
+
+	for _, row := range r.Keyboard {
+		buttons := make([]string, 0, len(row))
+		for _, button := range row {
+			buttons = append(buttons, "["+button.Text+"]")
+		}
+		b.WriteString("    ")
+		b.WriteString(strings.Join(buttons, " "))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package jbot
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRenderConsoleReply(t *testing.T) {
+	r := Reply{
+		Text:             "Try a button",
+		ReplyToMessageID: 3,
+		Keyboard:         [][]Button{{{"♒", "a"}, {"♓", "b"}}, {{"♈", "c"}}},
+	}
+
+	expected := "bot (reply to #3): Try a button\n" +
+		"    [♒] [♓]\n" +
+		"    [♈]\n"
+
+	if rendered := renderConsoleReply(r); rendered != expected {
+		t.Errorf("expected %q, got %q", expected, rendered)
+	}
+}
+
+func TestConsoleReceive(t *testing.T) {
+	var out bytes.Buffer
+	c := newConsole(strings.NewReader("/horosko\n[♓]\n[♉]\n*/horoskooppi\n"), &out)
+
+	// the first message gets a keyboard, so [♓] presses a button
+	// and [♉] that is not on the keyboard is just text
+	c.send(context.Background(), Reply{ChatID: consoleChatID, Keyboard: [][]Button{{{"♒", "♒"}, {"♓", "fish"}}}})
+
+	var updates []Update
+	err := c.receive(context.Background(), func(u Update) bool {
+		updates = append(updates, u)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updates) != 4 {
+		t.Fatalf("expected 4 updates, got %v", len(updates))
+	}
+	if updates[0].Message == nil || updates[0].Message.Text != "/horosko" {
+		t.Errorf("first line was not a message: %+v", updates[0])
+	}
+	if updates[1].Callback == nil || updates[1].Callback.Data != "fish" {
+		t.Errorf("pressing a button did not produce a callback: %+v", updates[1])
+	}
+	if updates[2].Message == nil || updates[2].Message.Text != "[♉]" {
+		t.Errorf("pressing a missing button was not a message: %+v", updates[2])
+	}
+	if updates[3].Kind != KindEditedMessage || updates[3].Message.Text != "/horoskooppi" || updates[3].Message.ID != updates[2].Message.ID {
+		t.Errorf("a line starting with * did not edit the last message: %+v", updates[3])
+	}
+	if updates[0].ChatID() != consoleChatID || updates[1].ChatID() != consoleChatID {
+		t.Error("console updates did not come from the console chat")
+	}
+}
@@ -0,0 +1,193 @@
+package jbot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchYears is how far ahead the next time of a cron expression
+// is searched for. Every valid expression matches within 5 years,
+// even one that only matches on the 29th of February.
+const cronSearchYears = 5
+
+// cronField is the set of values of one field of a cron expression.
+type cronField uint64
+
+func (f cronField) has(value int) bool {
+	return f&(1<<uint(value)) != 0
+}
+
+// cronSchedule is a parsed cron expression.
+type cronSchedule struct {
+	minute, hour, day, month, weekday cronField
+
+	// restricted day and weekday fields match if either of them matches
+	dayStar, weekdayStar bool
+}
+
+// cronMacros are the shorthands for common expressions.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonthNames   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronWeekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// parseCron parses a cron expression with the fields minute, hour,
+// day of month, month and day of week, such as "30 4 * * mon-fri".
+// A field is "*", a value, a range like "1-5" or a list of them like
+// "1,15". "*/15" and "0-30/10" step through a range. Months and
+// weekdays can also be written with their first three letters, and
+// both 0 and 7 are Sunday. The macros "@daily" and so on are accepted too.
+func parseCron(spec string) (*cronSchedule, error) {
+	expr := strings.ToLower(strings.TrimSpace(spec))
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q should have 5 fields, it has %v", spec, len(fields))
+	}
+
+	s := &cronSchedule{dayStar: fields[2] == "*", weekdayStar: fields[4] == "*"}
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute of %q: %v", spec, err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour of %q: %v", spec, err)
+	}
+	if s.day, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day of month of %q: %v", spec, err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("month of %q: %v", spec, err)
+	}
+	if s.weekday, err = parseCronField(fields[4], 0, 7, cronWeekdayNames); err != nil {
+		return nil, fmt.Errorf("day of week of %q: %v", spec, err)
+	}
+	if s.weekday.has(7) {
+		s.weekday |= 1
+	}
+
+	if s.next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, fmt.Errorf("cron expression %q never matches", spec)
+	}
+	return s, nil
+}
+
+// parseCronField parses one field of a cron expression whose values are
+// from min to max. names, if any, are the names of the values from min on.
+func parseCronField(field string, min, max int, names []string) (cronField, error) {
+	var set cronField
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		first, last := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if first, err = parseCronValue(bounds[0], min, max, names); err != nil {
+				return 0, err
+			}
+			if len(bounds) == 2 {
+				if last, err = parseCronValue(bounds[1], min, max, names); err != nil {
+					return 0, err
+				}
+			} else if step == 1 {
+				last = first
+			}
+			if first > last {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+
+		for value := first; value <= last; value += step {
+			set |= 1 << uint(value)
+		}
+	}
+	return set, nil
+}
+
+// parseCronValue parses a number or a name from min to max.
+func parseCronValue(value string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if value == name {
+			return min + i, nil
+		}
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%q is not a value from %v to %v", value, min, max)
+	}
+	return n, nil
+}
+
+// matchesDay returns true if s runs on the date of t.
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	if !s.month.has(int(t.Month())) {
+		return false
+	}
+
+	day, weekday := s.day.has(t.Day()), s.weekday.has(int(t.Weekday()))
+	switch {
+	case s.dayStar && s.weekdayStar:
+		return true
+	case s.dayStar:
+		return weekday
+	case s.weekdayStar:
+		return day
+	}
+	return day || weekday
+}
+
+// next returns the first time after t that matches s, in the time zone
+// of t. The clock times are those of the time zone, so "0 4 * * *" runs
+// at 04:00 also on the days the clocks change. A time that the clocks
+// skip runs when they have moved forward, and a time that occurs twice
+// runs once. next returns the zero time if nothing matches for years.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	location := t.Location()
+	year, month, day := t.Date()
+
+	for date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC); date.Year() <= year+cronSearchYears; date = date.AddDate(0, 0, 1) {
+		if !s.matchesDay(date) {
+			continue
+		}
+
+		for hour := 0; hour < 24; hour++ {
+			if !s.hour.has(hour) {
+				continue
+			}
+			for minute := 0; minute < 60; minute++ {
+				if !s.minute.has(minute) {
+					continue
+				}
+				candidate := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, location)
+				if candidate.After(t) {
+					return candidate
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
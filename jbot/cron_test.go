@@ -0,0 +1,97 @@
+package jbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronRejectsInvalidExpressions(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@fortnightly",
+		"0 0 30 2 *",
+	} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("cron expression %q was accepted", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	helsinki, err := time.LoadLocation("Europe/Helsinki")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, helsinki)
+	}
+
+	tests := []struct {
+		spec      string
+		now, next time.Time
+	}{
+		{"0 4 * * *", at(2026, 6, 20, 3, 59), at(2026, 6, 20, 4, 0)},
+		{"0 4 * * *", at(2026, 6, 20, 4, 0), at(2026, 6, 21, 4, 0)},
+		{"0 4 * * *", at(2026, 6, 20, 22, 30), at(2026, 6, 21, 4, 0)},
+		{"0 4 * * *", at(2026, 12, 31, 12, 0), at(2027, 1, 1, 4, 0)},
+		{"*/15 * * * *", at(2026, 6, 20, 10, 7), at(2026, 6, 20, 10, 15)},
+		{"0-30/10 9 * * *", at(2026, 6, 20, 9, 21), at(2026, 6, 20, 9, 30)},
+		{"0 9,17 * * *", at(2026, 6, 20, 9, 0), at(2026, 6, 20, 17, 0)},
+		{"0 9 * * mon-fri", at(2026, 6, 19, 10, 0), at(2026, 6, 22, 9, 0)},
+		{"0 0 * * 7", at(2026, 6, 20, 12, 0), at(2026, 6, 21, 0, 0)},
+		{"0 0 1 jan *", at(2026, 6, 20, 12, 0), at(2027, 1, 1, 0, 0)},
+		{"@monthly", at(2026, 6, 20, 12, 0), at(2026, 7, 1, 0, 0)},
+		{"0 0 29 2 *", at(2026, 6, 20, 12, 0), at(2028, 2, 29, 0, 0)},
+		// a restricted day of month and day of week match if either matches
+		{"0 12 13 * fri", at(2026, 6, 20, 12, 0), at(2026, 6, 26, 12, 0)},
+		// the clocks go from 03:00 to 04:00, so 03:30 is skipped and runs at 04:30
+		{"30 3 * * *", at(2026, 3, 29, 2, 0), time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC)},
+		// the clocks go from 04:00 back to 03:00, so 03:30 runs once
+		{"30 3 * * *", at(2026, 10, 25, 3, 45), at(2026, 10, 26, 3, 30)},
+	}
+	for _, test := range tests {
+		cron, err := parseCron(test.spec)
+		if err != nil {
+			t.Errorf("cron expression %q: %v", test.spec, err)
+			continue
+		}
+		if next := cron.next(test.now); !next.Equal(test.next) {
+			t.Errorf("%q: next run after %v was %v, expected %v", test.spec, test.now, next, test.next)
+		}
+	}
+}
+
+func TestCronNextOverClockChange(t *testing.T) {
+	helsinki, err := time.LoadLocation("Europe/Helsinki")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	cron, err := parseCron("0 4 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the clocks go forward on the night before, so the day is 23 hours long
+	now := time.Date(2026, 3, 28, 12, 0, 0, 0, helsinki)
+	next := cron.next(now)
+	if next.Sub(now) != 15*time.Hour || next.Hour() != 4 {
+		t.Errorf("expected 04:00 in 15 hours, got %v", next)
+	}
+
+	// the time zone of now is used
+	now = time.Date(2026, 6, 20, 1, 30, 0, 0, time.UTC)
+	if next := cron.next(now.In(helsinki)); !next.Equal(time.Date(2026, 6, 21, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected 04:00 in Helsinki, got %v", next)
+	}
+}
@@ -2,6 +2,7 @@ package jbot
 
 import (
 	"database/sql"
+	"errors"
 
 	_ "github.com/lib/pq" // blank import to use PostgreSQL
 )
@@ -10,3 +11,12 @@ import (
 func connected(d *sql.DB) bool {
 	return d.Ping() == nil
 }
+
+// databaseHealthy returns an error if d is not connected to a database.
+// It is the health check of the features that need the database.
+func databaseHealthy(d *sql.DB) error {
+	if !connected(d) {
+		return errors.New("no database connection")
+	}
+	return nil
+}
@@ -2,17 +2,15 @@
 package jbot
 
 import (
+	"context"
 	"errors"
-	"math/rand"
-	"regexp"
 	"strings"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	gjson "github.com/tidwall/gjson"
 )
 
 // decide is a feature of jbot
-// it responds to "keyword option1 option2 ..."
+// it responds to "keyword option1 option2 ..." or "keyword "option 1" "option 2" ..."
 // an option is randomly chosen.
 // certain words are never picked.
 // Other words are biased to be picked more often.
@@ -24,7 +22,7 @@ func (d *decide) String() string {
 	return "decide"
 }
 
-func (d *decide) init(bot *jbot) error {
+func (d *decide) Init(bot *Bot) error {
 
 	jsonConfig := gjson.GetBytes(bot.cfg.Features, "decide.aliases")
 	if !jsonConfig.Exists() {
@@ -37,28 +35,61 @@ func (d *decide) init(bot *jbot) error {
 	return nil
 }
 
-// triggers when one of the configured keywords is seen
-// as a prefix of a message seen by the bot
-func (d *decide) triggers(u tgbotapi.Update) bool {
-	return stringHasAnyPrefix(u.Message.Text, d.triggerWords)
+// Describe gives an example for every configured keyword
+func (d *decide) Describe() Description {
+	examples := []string{}
+	for _, word := range d.triggerWords {
+		examples = append(examples, word+" sauna lake \"both of them\"")
+	}
+	return Description{Examples: examples}
 }
 
-// execute sends the chosen option back to the user
-func (d *decide) execute(bot *jbot, u tgbotapi.Update) error {
-	message := u.Message.Text
+// Commands returns the configured keywords
+func (d *decide) Commands() []string {
+	return d.triggerWords
+}
 
-	// compress whitespace to single spaces
-	spaceRegexp := regexp.MustCompile(`\s+`)
-	trimmedMessage := spaceRegexp.ReplaceAllString(message, " ")
+// Triggers when the message is one of the configured commands
+func (d *decide) Triggers(u Update) bool {
+	return isCommand(u, d.triggerWords)
+}
 
-	// split incoming message string to words
-	inputWords := strings.Split(trimmedMessage, " ")
-	if len(inputWords) < 3 {
-		return nil
+// Execute sends the chosen option back to the user
+func (d *decide) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	if u.Command == nil {
+		return false, nil
 	}
 
-	// remove the command word (e.g. !decide)
-	inputWords = inputWords[1:]
+	chosenWord, ok := choose(u.Command.Args, bot.rand)
+	if !ok {
+		return true, nil
+	}
+	_, err := bot.Send(ctx, Reply{ChatID: u.Message.Chat.ID, Text: chosenWord})
+	return true, err
+}
+
+// AnswerInline offers the choice as a result that sends it to the chat
+func (d *decide) AnswerInline(ctx context.Context, bot *Bot, u Update) (InlineAnswer, error) {
+	chosenWord, ok := choose(u.Command.Args, bot.rand)
+	if !ok {
+		return InlineAnswer{}, nil
+	}
+
+	options := strings.Join(u.Command.Args, ", ")
+	return InlineAnswer{Results: []InlineResult{{
+		Title:       bot.Text(ctx, u, "decide.inline.title", options),
+		Description: bot.Text(ctx, u, "decide.inline.description"),
+		Text:        bot.Text(ctx, u, "decide.inline.text", options, chosenWord),
+	}}}, nil
+}
+
+// choose picks one of options with rnd. Quoted options may have many words.
+// It returns false if there are less than two options to choose from.
+func choose(options []string, rnd *random) (string, bool) {
+	inputWords := append([]string{}, options...)
+	if len(inputWords) < 2 {
+		return "", false
+	}
 
 	// maps lowercase inputs to original inputs
 	originalInputs := make(map[string]string)
@@ -73,18 +104,16 @@ func (d *decide) execute(bot *jbot, u tgbotapi.Update) error {
 	WordsToSkip := []string{"or", "vai", "tai", "vaiko"}
 	inputWords = filterWords(inputWords, WordsToSkip)
 	if len(inputWords) < 2 {
-		return nil
+		return "", false
 	}
 
 	// double the chance for drinking realted words to get chosen
 	preferredWords := []string{"kalja", "beer", "olut", "bisse", "kaljaa", "viina"}
 	inputWords = duplicateWords(inputWords, preferredWords)
 
-	chosenWord := inputWords[rand.Intn(len(inputWords))]
+	chosenWord := inputWords[rnd.intn(len(inputWords))]
 	chosenWord = originalInputs[chosenWord]
-	msg := tgbotapi.NewMessage(u.Message.Chat.ID, chosenWord)
-	bot.botAPI.Send(msg)
-	return nil
+	return chosenWord, true
 }
 
 func filterWords(words, wordsToRemove []string) []string {
@@ -1,6 +1,9 @@
 package jbot
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestFilterWordsTypical(t *testing.T) {
 	testWords := []string{
@@ -83,3 +86,38 @@ func TestDuplicateWordsEmpty(t *testing.T) {
 	}
 	return
 }
+
+func TestDecideExecute(t *testing.T) {
+	m := &recordingMessenger{}
+	bot := &Bot{messenger: m}
+	d := &decide{triggerWords: []string{"/decide"}}
+
+	u := commandUpdate("/decide sauna or lake")
+	if !d.Triggers(u) {
+		t.Fatalf("decide did not trigger on %q", u.Message.Text)
+	}
+	handled, err := d.Execute(context.Background(), bot, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !handled {
+		t.Error("decide did not report the update as handled")
+	}
+
+	if len(m.replies) != 1 {
+		t.Fatalf("expected 1 reply, got %v", len(m.replies))
+	}
+	if text := m.replies[0].Text; text != "sauna" && text != "lake" {
+		t.Errorf("decide picked %q, which was not an option", text)
+	}
+	if m.replies[0].ChatID != u.Message.Chat.ID {
+		t.Errorf("reply was sent to chat %v instead of %v", m.replies[0].ChatID, u.Message.Chat.ID)
+	}
+}
+
+func TestDecideIgnoresCallbacks(t *testing.T) {
+	d := &decide{triggerWords: []string{"/decide"}}
+	if d.Triggers(Update{Callback: &Callback{Data: "♒"}}) {
+		t.Error("decide triggered on a callback")
+	}
+}
@@ -0,0 +1,196 @@
+package jbot
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultWorkers       = 4
+	defaultQueueSize     = 100
+	defaultChatQueueSize = 10
+)
+
+// dispatcher runs a handler for updates on a pool of workers.
+// Updates from the same chat are handled one at a time in the order
+// they were submitted, while different chats are handled in parallel.
+// Chats take turns, so a busy chat can't starve the others. Updates
+// without a chat, like inline queries, are ordered by their sender.
+type dispatcher struct {
+	handle        func(Update)
+	workers       int
+	queueSize     int // max number of queued updates in total
+	chatQueueSize int // max number of queued updates per chat
+	logger        *Logger
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	pending  map[queueKey][]Update // queued updates of each chat
+	runnable []queueKey            // chats with queued updates and no active worker
+	queued   int
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// newDispatcher creates a dispatcher and starts its workers.
+// Non-positive limits are replaced with defaults.
+func newDispatcher(handle func(Update), workers, queueSize, chatQueueSize int, logger *Logger) *dispatcher {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	if chatQueueSize <= 0 {
+		chatQueueSize = defaultChatQueueSize
+	}
+
+	d := &dispatcher{
+		handle:        handle,
+		workers:       workers,
+		queueSize:     queueSize,
+		chatQueueSize: chatQueueSize,
+		logger:        logger,
+		pending:       make(map[queueKey][]Update),
+	}
+	d.cond = sync.NewCond(&d.mu)
+
+	d.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// queueKey identifies the queue of an update. The updates
+// with the same key are handled one at a time in order.
+type queueKey struct {
+	chat   int64 // chat of the update, 0 if it has none
+	user   int64 // sender of an update without a chat
+	update int   // ID of an update with neither, which is queued alone
+}
+
+// queueKeyOf returns the key of the queue of u: its chat, or its sender
+// if it has no chat, like inline queries and callbacks without a message.
+func queueKeyOf(u Update) queueKey {
+	switch {
+	case u.Message != nil:
+		return queueKey{chat: u.Message.Chat.ID}
+	case u.Callback != nil && u.Callback.Message != nil:
+		return queueKey{chat: u.Callback.Message.Chat.ID}
+	case u.BotMember != nil:
+		return queueKey{chat: u.BotMember.Chat.ID}
+	}
+	if sender := u.Sender(); sender != nil {
+		return queueKey{user: sender.ID}
+	}
+	return queueKey{update: u.ID}
+}
+
+// submit queues u for handling. It blocks while the total queue is full
+// and drops u if the queue of its chat is full.
+// It returns false if u was dropped.
+func (d *dispatcher) submit(u Update) bool {
+	key := queueKeyOf(u)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for d.queued >= d.queueSize && !d.closed {
+		d.cond.Wait()
+	}
+	if d.closed {
+		return false
+	}
+
+	queue, busy := d.pending[key]
+	if len(queue) >= d.chatQueueSize {
+		updateLogger(d.logger, u).Warn("dropping update, the queue of the chat is full")
+		return false
+	}
+
+	d.pending[key] = append(queue, u)
+	d.queued++
+	if !busy {
+		d.runnable = append(d.runnable, key)
+		d.cond.Broadcast()
+	}
+	return true
+}
+
+// close stops accepting updates and waits until the workers have
+// handled every update that was already queued.
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	d.closed = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+// closeWithTimeout is like close but gives up waiting after timeout.
+// It returns false if the workers did not finish in time.
+func (d *dispatcher) closeWithTimeout(timeout time.Duration) bool {
+	d.mu.Lock()
+	d.closed = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	return d.wait(timeout)
+}
+
+// wait waits at most timeout for the workers to finish after the
+// dispatcher was closed. It returns false if they did not finish in time.
+func (d *dispatcher) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// work handles queued updates until the dispatcher is closed and drained.
+func (d *dispatcher) work() {
+	defer d.wg.Done()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for {
+		for len(d.runnable) == 0 && !d.closed {
+			d.cond.Wait()
+		}
+		if len(d.runnable) == 0 {
+			return // closed and nothing left to do
+		}
+
+		// take the next update of the chat that has waited the longest
+		key := d.runnable[0]
+		d.runnable = d.runnable[1:]
+		u := d.pending[key][0]
+
+		d.mu.Unlock()
+		d.handle(u)
+		d.mu.Lock()
+
+		// the update stays in pending while it is handled,
+		// so submit knows not to make the chat runnable again
+		queue := d.pending[key][1:]
+		if len(queue) == 0 {
+			delete(d.pending, key)
+		} else {
+			d.pending[key] = queue
+			d.runnable = append(d.runnable, key)
+		}
+		d.queued--
+		d.cond.Broadcast()
+	}
+}
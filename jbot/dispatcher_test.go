@@ -0,0 +1,147 @@
+package jbot
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// chatUpdate returns an update with a message in chat chatID.
+func chatUpdate(updateID int, chatID int64) Update {
+	return Update{
+		ID:      updateID,
+		Message: &Message{Chat: Chat{ID: chatID}},
+	}
+}
+
+func TestDispatcherKeepsChatOrder(t *testing.T) {
+	var mu sync.Mutex
+	handled := make(map[int64][]int)
+
+	d := newDispatcher(func(u Update) {
+		mu.Lock()
+		defer mu.Unlock()
+		chatID := u.ChatID()
+		handled[chatID] = append(handled[chatID], u.ID)
+	}, 4, 100, 100, defaultLogger)
+
+	for i := 0; i < 30; i++ {
+		d.submit(chatUpdate(i, int64(i%3)))
+	}
+	d.close()
+
+	for chatID, updateIDs := range handled {
+		if len(updateIDs) != 10 {
+			t.Errorf("chat %v: expected 10 updates, got %v", chatID, len(updateIDs))
+		}
+		for i := 1; i < len(updateIDs); i++ {
+			if updateIDs[i] < updateIDs[i-1] {
+				t.Fatalf("chat %v: updates handled out of order: %v", chatID, updateIDs)
+			}
+		}
+	}
+}
+
+func TestDispatcherRunsChatsInParallel(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan int, 1)
+
+	d := newDispatcher(func(u Update) {
+		if u.ChatID() == 1 {
+			<-release // chat 1 hangs until chat 2 has been handled
+			return
+		}
+		done <- u.ID
+	}, 2, 10, 10, defaultLogger)
+	defer d.close()
+	defer close(release)
+
+	d.submit(chatUpdate(1, 1))
+	d.submit(chatUpdate(2, 2))
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a slow chat blocked another chat")
+	}
+}
+
+func TestDispatcherRunsUsersWithoutChatsInParallel(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan int, 2)
+
+	d := newDispatcher(func(u Update) {
+		if u.Sender() != nil && u.Sender().ID == 1 {
+			<-release // the inline query of user 1 hangs until the others have been handled
+			return
+		}
+		done <- u.ID
+	}, 3, 10, 10, defaultLogger)
+	defer d.close()
+	defer close(release)
+
+	inline := func(updateID int, sender *User) Update {
+		return Update{ID: updateID, Kind: KindInlineQuery, Inline: &Inline{Sender: sender}}
+	}
+	d.submit(inline(1, &User{ID: 1}))
+	d.submit(inline(2, &User{ID: 2}))
+	d.submit(inline(3, nil))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("a slow inline query blocked the updates of other users")
+		}
+	}
+}
+
+func TestDispatcherDropsWhenChatQueueIsFull(t *testing.T) {
+	release := make(chan struct{})
+
+	l, buf := bufferLogger(LogConfig{Format: "json"})
+	d := newDispatcher(func(u Update) {
+		<-release
+	}, 1, 10, 2, l)
+
+	if !d.submit(chatUpdate(1, 1)) || !d.submit(chatUpdate(2, 1)) {
+		t.Fatal("update was dropped from a chat queue that was not full")
+	}
+	dropped := chatUpdate(3, 1)
+	dropped.Message.Sender = &User{ID: 100}
+	if d.submit(dropped) {
+		t.Error("update was not dropped from a full chat queue")
+	}
+	lines := logLines(t, buf)
+	if len(lines) != 1 || lines[0]["update"] != 3.0 || lines[0]["kind"] != "message" || lines[0]["chat"] != 1.0 || lines[0]["user"] != 100.0 {
+		t.Errorf("the dropped update was not logged with its fields: %v", lines)
+	}
+	if !d.submit(chatUpdate(4, 2)) {
+		t.Error("a full chat queue caused updates of another chat to be dropped")
+	}
+
+	close(release)
+	d.close()
+}
+
+func TestDispatcherCloseWithTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	d := newDispatcher(func(u Update) {
+		<-release
+	}, 1, 10, 10, defaultLogger)
+	d.submit(chatUpdate(1, 1))
+
+	if d.closeWithTimeout(10 * time.Millisecond) {
+		t.Error("closeWithTimeout did not time out while an update was being handled")
+	}
+	if d.wait(10 * time.Millisecond) {
+		t.Error("wait did not time out while an update was being handled")
+	}
+
+	release <- struct{}{}
+	if !d.wait(time.Second) {
+		t.Error("wait timed out after the update was handled")
+	}
+}
@@ -0,0 +1,29 @@
+package jbot
+
+import (
+	"context"
+)
+
+// handleFeatureError logs and counts an error that feature returned
+// when it executed u. If an error reply is configured, it is sent to
+// the chat of u so that the user knows something went wrong.
+func handleFeatureError(ctx context.Context, bot *Bot, feature string, u Update, err error) {
+	errors := bot.stats.featureFailed(feature)
+
+	logger := bot.Log(ctx)
+	if p, ok := err.(*panicError); ok {
+		bot.stats.featurePanicked(feature)
+		logger.Error("feature panicked", "errors", errors, "error", err, "update_content", describeUpdate(u), "stack", p.stack)
+	} else {
+		logger.Error("feature failed", "errors", errors, "error", err)
+	}
+
+	if bot.cfg == nil || bot.cfg.ErrorReply == "" || u.ChatID() == 0 {
+		return
+	}
+
+	_, sendErr := bot.messenger.send(ctx, Reply{ChatID: u.ChatID(), Text: bot.cfg.ErrorReply})
+	if sendErr != nil {
+		logger.Error("failed to send error reply", "error", sendErr)
+	}
+}
@@ -0,0 +1,47 @@
+package jbot
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHandleFeatureErrorSendsErrorReply(t *testing.T) {
+	m := &recordingMessenger{}
+	failing := &fakeFeature{name: "failing_reply", trigger: true, handle: true, err: errors.New("boom")}
+	bot := &Bot{messenger: m, cfg: &Config{ErrorReply: "Something went wrong"}, features: []Feature{failing}}
+
+	u := textUpdate("hello")
+	dispatch(context.Background(), bot, u)
+
+	if len(m.replies) != 1 {
+		t.Fatalf("expected 1 reply, got %v", len(m.replies))
+	}
+	if m.replies[0].Text != "Something went wrong" || m.replies[0].ChatID != u.ChatID() {
+		t.Errorf("error reply was sent incorrectly: %+v", m.replies[0])
+	}
+}
+
+func TestHandleFeatureErrorCountsErrors(t *testing.T) {
+	m := &recordingMessenger{}
+	failing := &fakeFeature{name: "failing_count", trigger: true, err: errors.New("boom")}
+	next := &fakeFeature{name: "next", trigger: true}
+	bot := &Bot{messenger: m, cfg: &Config{}, features: []Feature{failing, next}, stats: newRunnerStats()}
+
+	for i := 0; i < 3; i++ {
+		dispatch(context.Background(), bot, textUpdate("hello"))
+	}
+
+	if count := bot.stats.featureErrors.Get("failing_count"); count == nil || count.String() != "3" {
+		t.Errorf("expected 3 errors, got %v", count)
+	}
+	if bot.stats.featureErrors.Get("next") != nil {
+		t.Error("errors were counted for a feature that did not fail")
+	}
+	if len(next.executed) != 3 {
+		t.Error("a failed feature that did not handle the update stopped the next feature")
+	}
+	if len(m.replies) != 0 {
+		t.Error("an error reply was sent without being configured")
+	}
+}
@@ -0,0 +1,171 @@
+package jbot
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Describer can be implemented by features to appear in /help.
+type Describer interface {
+	Describe() Description
+}
+
+// Description tells users what a feature does and how to use it.
+type Description struct {
+	Summary  string   // what the feature does in a sentence, unless the catalog has a text "<feature>.summary"
+	Examples []string // example messages, usually one per configured alias
+}
+
+// help is a feature that lists the features running in a chat
+// with their descriptions. It responds to "/help" and "/help command".
+type help struct {
+	aliases []string
+}
+
+func (h *help) String() string {
+	return "help"
+}
+
+func (h *help) Init(bot *Bot) error {
+	h.aliases = configuredAliases(bot.cfg.Features, "help.aliases", "/help")
+	return nil
+}
+
+func (h *help) Describe() Description {
+	examples := append([]string{}, h.aliases...)
+	examples = append(examples, h.name()+" <command>")
+	return Description{Examples: examples}
+}
+
+// name returns the command to mention in replies.
+func (h *help) name() string {
+	if len(h.aliases) == 0 {
+		return "/help"
+	}
+	return h.aliases[0]
+}
+
+func (h *help) Commands() []string {
+	return h.aliases
+}
+
+func (h *help) Triggers(u Update) bool {
+	return isCommand(u, h.aliases)
+}
+
+func (h *help) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	if u.Command == nil {
+		return false, nil
+	}
+
+	text := ""
+	if len(u.Command.Args) == 0 {
+		text = h.render(ctx, bot, u)
+	} else {
+		text = h.renderCommand(ctx, bot, u, u.Command.Args[0])
+	}
+
+	_, err := bot.Send(ctx, Reply{ChatID: u.ChatID(), Text: text})
+	return true, err
+}
+
+// render lists the described features that run in the chat of u.
+func (h *help) render(ctx context.Context, bot *Bot, u Update) string {
+	type entry struct {
+		name        string
+		description Description
+	}
+
+	entries := []entry{}
+	for _, feat := range bot.features {
+		if description, ok := describe(feat); ok && featureRunsInChat(ctx, bot, u.ChatID(), feat.String()) {
+			entries = append(entries, entry{feat.String(), translateDescription(bot, bot.Language(ctx, u), feat.String(), description)})
+		}
+	}
+	if len(entries) == 0 {
+		return bot.Text(ctx, u, "help.none")
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	var b strings.Builder
+	b.WriteString(bot.Text(ctx, u, "help.header") + "\n")
+	for _, e := range entries {
+		b.WriteString("\n")
+		writeDescription(&b, e.name, e.description)
+	}
+	b.WriteString("\n" + bot.Text(ctx, u, "help.more", h.name()))
+	return b.String()
+}
+
+// renderCommand describes the feature that owns command, if it runs in the chat of u.
+func (h *help) renderCommand(ctx context.Context, bot *Bot, u Update, command string) string {
+	name := strings.ToLower(command)
+	if !strings.HasPrefix(name, "/") && !strings.HasPrefix(name, "!") {
+		name = "/" + name
+	}
+
+	feat, owned := bot.commands[name]
+	if !owned || !featureRunsInChat(ctx, bot, u.ChatID(), feat.String()) {
+		return bot.Text(ctx, u, "help.unknown", name, h.name())
+	}
+
+	description, ok := describe(feat)
+	if !ok {
+		return bot.Text(ctx, u, "help.nodescription", name, feat.String())
+	}
+
+	var b strings.Builder
+	writeDescription(&b, feat.String(), translateDescription(bot, bot.Language(ctx, u), feat.String(), description))
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeDescription writes the description of the feature called name to b.
+func writeDescription(b *strings.Builder, name string, description Description) {
+	b.WriteString(name)
+	b.WriteString(" – ")
+	b.WriteString(description.Summary)
+	b.WriteString("\n")
+	for _, example := range description.Examples {
+		b.WriteString("    ")
+		b.WriteString(example)
+		b.WriteString("\n")
+	}
+}
+
+// translateDescription replaces the summary of the feature called name
+// with the text "name.summary" of the catalog in language, or in the
+// default language if there is none in language. Features that have
+// no such text keep the summary of their description.
+func translateDescription(bot *Bot, language string, name string, description Description) Description {
+	key := name + ".summary"
+	for _, l := range []string{language, defaultLanguage} {
+		if summary, ok := bot.catalog.lookup(l, key); ok {
+			description.Summary = summary
+			return description
+		}
+	}
+	if summary, ok := defaultMessages[key]; ok {
+		description.Summary = summary
+	}
+	return description
+}
+
+// featureRunsInChat returns true if the feature called name
+// has not been disabled in chatID or for panicking.
+func featureRunsInChat(ctx context.Context, bot *Bot, chatID int64, name string) bool {
+	if bot.panics.isDisabled(name) {
+		return false
+	}
+	return bot.toggles == nil || bot.toggles.enabled(ctx, chatID, name)
+}
+
+// describe returns the description of feat, looking through middlewares.
+func describe(feat Feature) (Description, bool) {
+	for _, f := range featureChain(feat) {
+		if describer, ok := f.(Describer); ok {
+			return describer.Describe(), true
+		}
+	}
+	return Description{}, false
+}
@@ -0,0 +1,86 @@
+package jbot
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestHelpListsRunningFeatures(t *testing.T) {
+	m := &recordingMessenger{}
+	h := &help{aliases: []string{"/help", "/start"}}
+	d := &decide{triggerWords: []string{"/decide", "/choose"}}
+	pingpongFeat := &pingpong{features: []pingpongFeature{{Pings: []string{"/ping"}, IsPrefixCommand: true}}}
+	features := []Feature{h, d, pingpongFeat, &fakeFeature{name: "undescribed"}}
+	bot := &Bot{
+		messenger: m,
+		features:  features,
+		commands:  newCommandTable(features),
+		panics:    newPanicGuard(1, newLogger(log.New(ioutil.Discard, "", 0))),
+	}
+	bot.panics.record("pingpong", &panicError{value: "boom"})
+
+	dispatch(context.Background(), bot, textUpdate("/start"))
+	if len(m.replies) != 1 {
+		t.Fatalf("expected 1 reply, got %v", len(m.replies))
+	}
+
+	text := m.replies[0].Text
+	for _, want := range []string{"decide – ", "/decide sauna lake", "/choose sauna lake", "help – ", "/help <command>"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("help is missing %q:\n%v", want, text)
+		}
+	}
+	if strings.Contains(text, "pingpong") || strings.Contains(text, "undescribed") {
+		t.Errorf("help lists a disabled or undescribed feature:\n%v", text)
+	}
+}
+
+func TestHelpForOneCommand(t *testing.T) {
+	m := &recordingMessenger{}
+	h := &help{aliases: []string{"/help"}}
+	d := &decide{triggerWords: []string{"/decide", "/choose"}}
+	features := []Feature{h, d}
+	bot := &Bot{messenger: m, features: features, commands: newCommandTable(features)}
+
+	dispatch(context.Background(), bot, textUpdate("/help choose"))
+	dispatch(context.Background(), bot, textUpdate("/help /nonexistent"))
+
+	if len(m.replies) != 2 {
+		t.Fatalf("expected 2 replies, got %v", len(m.replies))
+	}
+	if !strings.HasPrefix(m.replies[0].Text, "decide – ") || strings.Contains(m.replies[0].Text, "help") {
+		t.Errorf("help for /choose did not describe decide only:\n%v", m.replies[0].Text)
+	}
+	if m.replies[1].Text != "Unknown command /nonexistent. Try /help" {
+		t.Errorf("unexpected reply to an unknown command: %q", m.replies[1].Text)
+	}
+}
+
+func TestHelpTranslatesSummaries(t *testing.T) {
+	m := &recordingMessenger{}
+	h := &help{aliases: []string{"/help"}}
+	d := &decide{triggerWords: []string{"/decide"}}
+	features := []Feature{h, d}
+	bot := &Bot{
+		messenger: m,
+		features:  features,
+		commands:  newCommandTable(features),
+		cfg:       &Config{Language: "fi"},
+		catalog:   catalog{"fi": {"decide.summary": "Valitsee puolestasi.", "help.header": "Ominaisuudet:"}},
+	}
+
+	dispatch(context.Background(), bot, textUpdate("/help"))
+	if len(m.replies) != 1 {
+		t.Fatalf("expected 1 reply, got %v", len(m.replies))
+	}
+
+	text := m.replies[0].Text
+	for _, want := range []string{"Ominaisuudet:", "decide – Valitsee puolestasi.", "help – Lists the features"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("help is missing %q:\n%v", want, text)
+		}
+	}
+}
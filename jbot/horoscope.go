@@ -2,29 +2,48 @@
 package jbot
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	gjson "github.com/tidwall/gjson"
 )
 
+// horoscopeInlineCacheTime is how long the answers to inline queries
+// are cached. The horoscopes change once a day.
+const horoscopeInlineCacheTime = 10 * time.Minute
+
+const (
+	defaultHoroscopeURL        = "http://theastrologer-api.herokuapp.com/api/horoscope/{sign}/today"
+	defaultHoroscopeUpdateHour = 4
+
+	// horoscopeRetryInterval is the wait before the first retry of a failed
+	// update. The wait doubles after every retry up to maxHoroscopeRetryInterval.
+	horoscopeRetryInterval    = time.Minute
+	maxHoroscopeRetryInterval = time.Hour
+
+	horoscopeTimeFormat = "2006-01-02 15:04 MST"
+
+	horoscopeUpdateJob = "horoscope.update"
+)
+
 type horoscope struct {
 	triggerWords []string
+	updater      horoscopeUpdater
 }
 
 func (h *horoscope) String() string {
 	return "horoscope"
 }
 
-func (h *horoscope) init(bot *jbot) error {
+func (h *horoscope) Init(bot *Bot) error {
 
 	if !connected(bot.database) {
 		return errors.New("no database connection")
@@ -48,55 +67,155 @@ func (h *horoscope) init(bot *jbot) error {
 		h.triggerWords = append(h.triggerWords, jsonWord.String())
 	}
 
-	return nil
+	h.updater, err = configureHoroscopeUpdater(bot.cfg.Features)
+	return err
+}
+
+// Healthy returns an error if the database connection is lost.
+func (h *horoscope) Healthy(bot *Bot) error {
+	return databaseHealthy(bot.database)
 }
 
-func (h *horoscope) triggers(u tgbotapi.Update) bool {
+func (h *horoscope) Describe() Description {
+	examples := []string{}
+	for _, word := range h.triggerWords {
+		examples = append(examples, word, word+" leo", word+" status")
+	}
+	return Description{Examples: examples}
+}
+
+func (h *horoscope) Commands() []string {
+	return h.triggerWords
+}
+
+func (h *horoscope) Triggers(u Update) bool {
 	if u.Message != nil {
-		return stringHasAnyPrefix(u.Message.Text, h.triggerWords)
-	} else if u.CallbackQuery != nil {
-		return true
+		return isCommand(u, h.triggerWords)
+	} else if u.Callback != nil {
+		// only the buttons of horoscope, other features have callbacks too
+		return convertEmojiToHoroscopeSign(u.Callback.Data) != horoscopeSignNone
 	}
 
 	return false
 }
 
-func (h *horoscope) execute(bot *jbot, u tgbotapi.Update) error {
+func (h *horoscope) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
 
 	text := ""
 
-	if u.CallbackQuery != nil {
+	if u.Callback != nil {
 
-		text, err := resolveHoroscope(convertEmojiToHoroscopeSign(u.CallbackQuery.Data), bot.database)
+		text, err := resolveHoroscope(ctx, bot, u, convertEmojiToHoroscopeSign(u.Callback.Data))
 		if err != nil {
-			return err
+			return true, err
 		}
 
-		bot.botAPI.AnswerCallbackQuery(tgbotapi.NewCallback(u.CallbackQuery.ID, "Fortune delivered"))
-		bot.botAPI.Send(tgbotapi.NewMessage(u.CallbackQuery.Message.Chat.ID, text))
-		return nil
+		if err = bot.AnswerCallback(ctx, u.Callback.ID, bot.Text(ctx, u, "horoscope.delivered")); err != nil {
+			return true, err
+		}
+		_, err = bot.Send(ctx, Reply{ChatID: u.ChatID(), Text: text})
+		return true, err
 
 	}
 
+	if u.Command == nil {
+		return false, nil
+	}
+
+	if len(u.Command.Args) > 0 && strings.ToLower(u.Command.Args[0]) == "status" {
+		text, err := h.status(ctx, bot, u)
+		if err != nil {
+			return true, err
+		}
+		_, err = bot.Send(ctx, Reply{ChatID: u.ChatID(), Text: text})
+		return true, err
+	}
+
 	chatID := u.Message.Chat.ID
-	sign := parseHoroscopeMessage(u.Message.Text)
+	sign := parseHoroscopeMessage(strings.Join(u.Command.Args, " "))
 
+	var err error
 	if sign == horoscopeSignNone {
-		text = "Try a button"
-		msg := tgbotapi.NewMessage(chatID, text)
-		msg.ReplyMarkup = getSignKeyboard()
-		bot.botAPI.Send(msg)
+		text = bot.Text(ctx, u, "horoscope.button")
+		_, err = bot.Send(ctx, Reply{ChatID: chatID, Text: text, Keyboard: getSignKeyboard()})
 	} else {
-		text, err := resolveHoroscope(sign, bot.database)
+		text, err = resolveHoroscope(ctx, bot, u, sign)
 		if err != nil {
-			text = "Horoscope failed"
+			text = bot.Text(ctx, u, "horoscope.failed")
 		}
 
-		msg := tgbotapi.NewMessage(chatID, text)
-		bot.botAPI.Send(msg)
+		_, err = bot.Send(ctx, Reply{ChatID: chatID, Text: text})
 	}
-	return nil
+	return true, err
+
+}
+
+// AnswerInline offers the horoscope of the sign in the query,
+// or the horoscopes of all signs if the query has none.
+func (h *horoscope) AnswerInline(ctx context.Context, bot *Bot, u Update) (InlineAnswer, error) {
+	signs := []horoscopeSign{parseHoroscopeMessage(strings.Join(u.Command.Args, " "))}
+	if signs[0] == horoscopeSignNone {
+		signs = allHoroscopeSigns()
+	}
+
+	answer := InlineAnswer{CacheTime: horoscopeInlineCacheTime}
+	for _, sign := range signs {
+		text, err := resolveHoroscope(ctx, bot, u, sign)
+		if err != nil {
+			return InlineAnswer{}, err
+		}
+		answer.Results = append(answer.Results, InlineResult{
+			Title:       horoscopeSignEmoji(sign) + " " + sign.String(),
+			Description: bot.Text(ctx, u, "horoscope.inline.description"),
+			Text:        text,
+		})
+	}
+	return answer, nil
+}
+
+// status returns how the updates of the horoscopes have gone,
+// if the sender of u is an administrator of the chat.
+func (h *horoscope) status(ctx context.Context, bot *Bot, u Update) (string, error) {
+	allowed, err := canChangeFeatures(ctx, bot, u.Message)
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return bot.Text(ctx, u, "horoscope.status.admins"), nil
+	}
+
+	status := bot.stats.horoscopeUpdates().snapshot()
+	location := h.location(bot)
+
+	lines := []string{}
+	if status.LastSuccess.IsZero() {
+		lines = append(lines, bot.Text(ctx, u, "horoscope.status.never"))
+	} else {
+		lines = append(lines, bot.Text(ctx, u, "horoscope.status.success", status.LastSuccess.In(location).Format(horoscopeTimeFormat)))
+	}
+	if status.LastError != "" {
+		lines = append(lines, bot.Text(ctx, u, "horoscope.status.error", status.Failures, status.LastError))
+	}
+	if !h.updater.enabled {
+		lines = append(lines, bot.Text(ctx, u, "horoscope.status.disabled"))
+	} else if jobs, err := bot.ScheduledJobs(ctx, h); err == nil {
+		for _, job := range jobs {
+			if job.Name == horoscopeUpdateJob {
+				lines = append(lines, bot.Text(ctx, u, "horoscope.status.next", job.Next.In(location).Format(horoscopeTimeFormat)))
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
 
+// location returns the time zone of the daily update.
+func (h *horoscope) location(bot *Bot) *time.Location {
+	if bot.schedule != nil {
+		if location, err := bot.schedule.locationOf(h.updater.job()); err == nil {
+			return location
+		}
+	}
+	return time.Local
 }
 
 // horoscopeData contains data from
@@ -163,41 +282,24 @@ func (sign horoscopeSign) String() string {
 }
 
 // horoscopeReply builds a reply string from horoscopeData
-func horoscopeReply(hresponse horoscopeData) (reply string) {
+// in the language of u.
+func horoscopeReply(ctx context.Context, bot *Bot, u Update, hresponse horoscopeData) (reply string) {
 
-	reply = "The Angels transfer your horoscope:\n👼👼👼\n" +
-		hresponse.Text + "\n👼👼 👼 \n\nKeywords: " +
-		hresponse.Meta.Keywords + "\n\nMood: " +
-		hresponse.Meta.Mood + "\n\nEnergy level of transfer: " +
-		hresponse.Meta.Intensity + "."
+	reply = bot.Text(ctx, u, "horoscope.reply",
+		hresponse.Text, hresponse.Meta.Keywords, hresponse.Meta.Mood, hresponse.Meta.Intensity)
 
 	return
 }
 
 // getSignKeyboard returns an inline keyboard with buttons for
 // all horoscope signs.
-func getSignKeyboard() tgbotapi.InlineKeyboardMarkup {
-
-	return tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("♒", "♒"),
-			tgbotapi.NewInlineKeyboardButtonData("♓", "♓"),
-			tgbotapi.NewInlineKeyboardButtonData("♈", "♈"),
-			tgbotapi.NewInlineKeyboardButtonData("♉", "♉"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("♊", "♊"),
-			tgbotapi.NewInlineKeyboardButtonData("♋", "♋"),
-			tgbotapi.NewInlineKeyboardButtonData("♌", "♌"),
-			tgbotapi.NewInlineKeyboardButtonData("♍", "♍"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("♎", "♎"),
-			tgbotapi.NewInlineKeyboardButtonData("♏", "♏"),
-			tgbotapi.NewInlineKeyboardButtonData("♐", "♐"),
-			tgbotapi.NewInlineKeyboardButtonData("♑", "♑"),
-		),
-	)
+func getSignKeyboard() [][]Button {
+
+	return [][]Button{
+		{{"♒", "♒"}, {"♓", "♓"}, {"♈", "♈"}, {"♉", "♉"}},
+		{{"♊", "♊"}, {"♋", "♋"}, {"♌", "♌"}, {"♍", "♍"}},
+		{{"♎", "♎"}, {"♏", "♏"}, {"♐", "♐"}, {"♑", "♑"}},
+	}
 
 }
 
@@ -225,6 +327,18 @@ func convertEmojiToHoroscopeSign(emoji string) (sign horoscopeSign) {
 	return emojiToHoroscopeMap[emoji]
 }
 
+// horoscopeSignEmoji returns the emoji of sign.
+func horoscopeSignEmoji(sign horoscopeSign) string {
+	for _, row := range getSignKeyboard() {
+		for _, button := range row {
+			if convertEmojiToHoroscopeSign(button.Data) == sign {
+				return button.Data
+			}
+		}
+	}
+	return ""
+}
+
 // parseHoroscopeMessage searches originalMessage for certain
 // key phrases and returns a corresponding horoscopeSign if one is found.
 func parseHoroscopeMessage(originalMessage string) horoscopeSign {
@@ -260,16 +374,16 @@ func parseHoroscopeMessage(originalMessage string) horoscopeSign {
 
 // resolveHoroscope provides a string to send to the user
 // based on a horoscopeSign.
-func resolveHoroscope(sign horoscopeSign, database *sql.DB) (reply string, err error) {
-	hresponse := getHoroscopeData(database, sign)
-	reply = horoscopeReply(hresponse)
+func resolveHoroscope(ctx context.Context, bot *Bot, u Update, sign horoscopeSign) (reply string, err error) {
+	hresponse := getHoroscopeData(ctx, bot.database, sign)
+	reply = horoscopeReply(ctx, bot, u, hresponse)
 	return
 }
 
 // getHoroscopeData queries the database for the data of a particular sign
-func getHoroscopeData(database *sql.DB, sign horoscopeSign) (data horoscopeData) {
+func getHoroscopeData(ctx context.Context, database *sql.DB, sign horoscopeSign) (data horoscopeData) {
 
-	rows, err := database.Query("SELECT datestring, signstring, text, intensity, keywords, mood FROM horoscope WHERE signstring = $1", sign.String())
+	rows, err := database.QueryContext(ctx, "SELECT datestring, signstring, text, intensity, keywords, mood FROM horoscope WHERE signstring = $1", sign.String())
 	if err != nil {
 		return
 	}
@@ -289,40 +403,207 @@ func getHoroscopeData(database *sql.DB, sign horoscopeSign) (data horoscopeData)
 	return
 }
 
-// startHoroscopeUpdater starts a process that updates all horoscopes
-// in the database daily at roughly 04:00 / 4am
-func startHoroscopeUpdater(database *sql.DB) {
+// horoscopeUpdater is the config of the daily update of the horoscopes.
+type horoscopeUpdater struct {
+	enabled  bool
+	cron     string // when to update, e.g. "0 4 * * *"
+	timezone string // time zone of cron, empty for the "timezone" of the config
+	url      string // address of the horoscope of a sign, with {sign} in place of the sign
+	onStart  bool   // if true, the horoscopes are also updated when the bot starts
+}
+
+// configureHoroscopeUpdater reads the config of the daily update from
+// features.horoscope.update. The update is enabled unless it is turned off.
+func configureHoroscopeUpdater(features []byte) (horoscopeUpdater, error) {
+	jsonConfig := gjson.GetBytes(features, "horoscope.update")
+
+	updater := horoscopeUpdater{
+		enabled:  !jsonConfig.Get("enabled").Exists() || jsonConfig.Get("enabled").Bool(),
+		cron:     fmt.Sprintf("0 %v * * *", defaultHoroscopeUpdateHour),
+		timezone: jsonConfig.Get("timezone").String(),
+		url:      defaultHoroscopeURL,
+		onStart:  jsonConfig.Get("onstart").Bool(),
+	}
+
+	if at := jsonConfig.Get("at").String(); at != "" {
+		t, err := time.Parse("15:04", at)
+		if err != nil {
+			return horoscopeUpdater{}, fmt.Errorf("invalid horoscope update time %q, expected e.g. \"04:00\"", at)
+		}
+		updater.cron = fmt.Sprintf("%v %v * * *", t.Minute(), t.Hour())
+	}
+	if cron := jsonConfig.Get("cron").String(); cron != "" {
+		if _, err := parseCron(cron); err != nil {
+			return horoscopeUpdater{}, fmt.Errorf("invalid horoscope update cron: %v", err)
+		}
+		updater.cron = cron
+	}
+
+	if updater.timezone != "" {
+		if _, err := time.LoadLocation(updater.timezone); err != nil {
+			return horoscopeUpdater{}, fmt.Errorf("invalid horoscope update timezone: %v", err)
+		}
+	}
+
+	if url := jsonConfig.Get("url").String(); url != "" {
+		updater.url = url
+	}
+
+	return updater, nil
+}
+
+// job returns the scheduled job of the daily update.
+func (updater horoscopeUpdater) job() Job {
+	return Job{Name: horoscopeUpdateJob, Cron: updater.cron, Timezone: updater.timezone}
+}
+
+// Jobs returns the daily update, unless it is turned off.
+func (h *horoscope) Jobs() []Job {
+	if !h.updater.enabled {
+		return nil
+	}
+	return []Job{h.updater.job()}
+}
+
+// RunJob updates the horoscopes. A failed update is retried
+// with a growing wait until it is time for the next one.
+// A run that catches up with a missed update is skipped if the
+// horoscopes were updated after it was due, e.g. when the bot started.
+func (h *horoscope) RunJob(ctx context.Context, bot *Bot, job Job) error {
+	if job.Name != horoscopeUpdateJob || !h.updater.enabled {
+		return nil
+	}
+	return h.refresh(ctx, bot, job.Next)
+}
 
-	durationToNextFourAm := time.Duration(24+4-time.Now().Hour())*time.Hour +
-		time.Duration(time.Now().Minute())*time.Minute +
-		time.Duration(time.Now().Second())*time.Second
+// Work updates the horoscopes when the bot starts, if that is configured
+// and they have not been updated yet.
+func (h *horoscope) Work(ctx context.Context, bot *Bot) {
+	if !h.updater.enabled || !h.updater.onStart {
+		return
+	}
 
-	time.AfterFunc(durationToNextFourAm, func() { updateHoroscopeDaily(database) })
+	if err := h.refresh(ctx, bot, time.Time{}); err != nil {
+		bot.Log(ctx).Error("failed to update horoscopes", "error", err)
+	}
 }
 
-// updateHoroscopeDaily starts a repeating goroutine
-// that updates all horoscopes once every 24 hours.
-func updateHoroscopeDaily(database *sql.DB) {
+// refresh updates the horoscopes unless they have been updated after
+// since. Only one update runs at a time, so when the update at start
+// and a missed daily update run together, the data is fetched once.
+func (h *horoscope) refresh(ctx context.Context, bot *Bot, since time.Time) error {
+	updates := bot.stats.horoscopeUpdates()
+	updates.updating.Lock()
+	defer updates.updating.Unlock()
+
+	if last := updates.lastSuccess(); !last.IsZero() && last.After(since) {
+		bot.Log(ctx).Info("horoscopes are up to date", "updated", last)
+		return nil
+	}
+	return h.update(ctx, bot, h.nextUpdate(bot, time.Now()))
+}
+
+// nextUpdate returns the time of the first daily update after now.
+func (h *horoscope) nextUpdate(bot *Bot, now time.Time) time.Time {
+	if bot.schedule != nil {
+		next, err := bot.schedule.nextRun(h.updater.job(), now)
+		if err == nil && !next.IsZero() {
+			return next
+		}
+	}
+	return now.Add(24 * time.Hour)
+}
 
-	log.Println("Horoscopes are updating for the first time: starting the daily updater")
-	// call updateHoroscopeData one time
-	updateAllHoroscopeData(database)
+// update updates all horoscopes, retrying until it succeeds,
+// ctx is done or the next retry would be after giveUp.
+func (h *horoscope) update(ctx context.Context, bot *Bot, giveUp time.Time) error {
+	wait := horoscopeRetryInterval
+	updates := bot.stats.horoscopeUpdates()
+	for {
+		err := updateAllHoroscopeData(ctx, bot.Database(), bot.HTTPClient(), h.updater.url)
+		updates.record(time.Now(), err)
+		if err == nil {
+			bot.Log(ctx).Info("horoscopes updated")
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 
-	// start an endless anonymous go routine of daily updating
-	go func() {
-		for range time.NewTicker(24 * time.Hour).C {
-			log.Println("Attempting to fetch new horoscopes...")
-			updateAllHoroscopeData(database)
+		if time.Now().Add(wait).After(giveUp) {
+			return fmt.Errorf("giving up until %v: %v", giveUp.Format(horoscopeTimeFormat), err)
+		}
+		bot.Log(ctx).Warn("failed to update horoscopes, retrying", "wait", wait, "error", err)
+		if !sleepUntil(ctx, time.Now().Add(wait)) {
+			return ctx.Err()
 		}
-	}()
+		if wait *= 2; wait > maxHoroscopeRetryInterval {
+			wait = maxHoroscopeRetryInterval
+		}
+	}
+}
+
+// sleepUntil waits until t. It returns false if ctx was done first.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
 
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
-// updateAllHoroscopeData updates the database rows for
-// all of the horoscopes
-func updateAllHoroscopeData(database *sql.DB) {
+// horoscopeUpdateStatus tells how the updates of the horoscopes have gone.
+// It is published with expvar and shown by "/horoscope status".
+type horoscopeUpdateStatus struct {
+	LastSuccess time.Time `json:"last_success"`
+	LastAttempt time.Time `json:"last_attempt"`
+	LastError   string    `json:"last_error"` // error of the last attempt, empty if it succeeded
+	Failures    int       `json:"failures"`   // failed attempts since the last success
+}
+
+// horoscopeUpdateLog keeps the status of the updates of a Runner.
+type horoscopeUpdateLog struct {
+	updating sync.Mutex // held while the horoscopes are updated
+
+	mu     sync.Mutex
+	status horoscopeUpdateStatus
+}
+
+// record records the result of an attempt to update the horoscopes.
+func (l *horoscopeUpdateLog) record(at time.Time, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.status.LastAttempt = at
+	if err != nil {
+		l.status.LastError = err.Error()
+		l.status.Failures++
+		return
+	}
+	l.status.LastSuccess, l.status.LastError, l.status.Failures = at, "", 0
+}
+
+func (l *horoscopeUpdateLog) lastSuccess() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.status.LastSuccess
+}
+
+func (l *horoscopeUpdateLog) snapshot() horoscopeUpdateStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.status
+}
 
-	signs := [12]horoscopeSign{
+// allHoroscopeSigns returns every horoscopeSign except horoscopeSignNone.
+func allHoroscopeSigns() []horoscopeSign {
+	return []horoscopeSign{
 		horoscopeSignAquarius,
 		horoscopeSignPisces,
 		horoscopeSignAries,
@@ -336,50 +617,74 @@ func updateAllHoroscopeData(database *sql.DB) {
 		horoscopeSignSagittarius,
 		horoscopeSignCapricorn,
 	}
+}
 
-	for _, sign := range signs {
-		updateHoroscopeData(database, sign)
+// updateAllHoroscopeData updates the database rows for
+// all of the horoscopes. It stops at the first sign that fails.
+func updateAllHoroscopeData(ctx context.Context, database *sql.DB, client *http.Client, url string) error {
+
+	for _, sign := range allHoroscopeSigns() {
+		if err := updateHoroscopeData(ctx, database, client, url, sign); err != nil {
+			return fmt.Errorf("%v: %v", sign, err)
+		}
 	}
-	return
+	return nil
 }
 
 // updateHoroscopeData fetches the new horoscope of the day for a
 // partucular horoscopeSign and updates that data to the database.
-func updateHoroscopeData(database *sql.DB, sign horoscopeSign) {
+// The row of the sign is added if the table does not have it yet.
+func updateHoroscopeData(ctx context.Context, database *sql.DB, client *http.Client, url string, sign horoscopeSign) error {
 
-	data, err := httpGetHoroscopeData(sign)
+	data, err := httpGetHoroscopeData(ctx, client, url, sign)
 	if err != nil {
-		log.Println("Failed to get new horoscopes from the web, database not updated")
-		return
+		return err
 	}
 
-	rows, err := database.Query("UPDATE horoscope SET (datestring, text, intensity, keywords, mood) = ($1, $2, $3, $4, $5) WHERE signstring = $6", data.Date, data.Text, data.Meta.Intensity, data.Meta.Keywords, data.Meta.Mood, strings.ToLower(data.Sunsign))
+	result, err := database.ExecContext(ctx, "UPDATE horoscope SET (datestring, text, intensity, keywords, mood) = ($1, $2, $3, $4, $5) WHERE signstring = $6", data.Date, data.Text, data.Meta.Intensity, data.Meta.Keywords, data.Meta.Mood, sign.String())
 	if err != nil {
-		log.Println("Error with the database, database not updated")
-		return
+		return fmt.Errorf("database error: %v", err)
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated > 0 {
+		return nil
 	}
-	defer rows.Close()
 
-	log.Println("Updated data to the database,", sign.String())
-	return
+	_, err = database.ExecContext(ctx, "INSERT INTO horoscope (datestring, signstring, text, intensity, keywords, mood) VALUES ($1, $2, $3, $4, $5, $6)", data.Date, sign.String(), data.Text, data.Meta.Intensity, data.Meta.Keywords, data.Meta.Mood)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	return nil
 }
 
 // httpGetHoroscopeData fetches the new horoscopeData for the day for
-// a particular horoscopeSign. The data comes from a REST API whose
-// url is hard coded inside this function.
-func httpGetHoroscopeData(sign horoscopeSign) (data horoscopeData, err error) {
+// a particular horoscopeSign from url, which has {sign} in place
+// of the name of the sign.
+func httpGetHoroscopeData(ctx context.Context, client *http.Client, url string, sign horoscopeSign) (data horoscopeData, err error) {
+
+	request, err := http.NewRequest(http.MethodGet, strings.Replace(url, "{sign}", sign.String(), -1), nil)
+	if err != nil {
+		return
+	}
 
-	response, err := http.Get("http://theastrologer-api.herokuapp.com/api/horoscope/" + sign.String() + "/today")
+	response, err := client.Do(request.WithContext(ctx))
 	if err != nil {
 		return
 	}
 	defer response.Body.Close()
 
+	if response.StatusCode != http.StatusOK {
+		err = fmt.Errorf("horoscope API responded %v", response.Status)
+		return
+	}
+
 	bodyBytes, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		return
 	}
 
 	err = json.Unmarshal(bodyBytes, &data)
+	if err == nil && data.Text == "" {
+		err = errors.New("horoscope API sent no horoscope")
+	}
 	return
 }
@@ -1,7 +1,14 @@
 package jbot
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
@@ -61,6 +68,16 @@ func TestConvertEmojiToHoroscopeSign(t *testing.T) {
 	}
 }
 
+func TestHoroscopeTriggersOnItsButtons(t *testing.T) {
+	h := &horoscope{}
+	for data, expected := range map[string]bool{"♌": true, "decide:again": false, "": false} {
+		u := Update{ID: 1, Callback: &Callback{ID: "c", Data: data}}
+		if h.Triggers(u) != expected {
+			t.Errorf("the callback %q triggered horoscope: %v", data, !expected)
+		}
+	}
+}
+
 func TestParseHoroscopeMessageAries(t *testing.T) {
 	originalMessage := "oinas"
 	targetOutput := horoscopeSignAries
@@ -137,7 +154,7 @@ func TestGetHoroscopeData(t *testing.T) {
 
 	expectedContents := horoscopeData{"1.1.1980", "sagittarius", "Good fortune for your friend but not you", horoscopeMeta{"5 percent", "keyword1, keyword2", "neutral"}}
 
-	contents := getHoroscopeData(db, horoscopeSignSagittarius)
+	contents := getHoroscopeData(context.Background(), db, horoscopeSignSagittarius)
 	if err != nil {
 		t.Errorf("error was not expected: %s", err)
 	}
@@ -151,3 +168,177 @@ func TestGetHoroscopeData(t *testing.T) {
 	}
 
 }
+
+func TestConfigureHoroscopeUpdater(t *testing.T) {
+	updater, err := configureHoroscopeUpdater([]byte(`{"horoscope": {"aliases": ["/horoscope"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updater.enabled || updater.cron != "0 4 * * *" || updater.timezone != "" || updater.url != defaultHoroscopeURL {
+		t.Errorf("unexpected default config %+v", updater)
+	}
+
+	updater, err = configureHoroscopeUpdater([]byte(`{"horoscope": {"update": {"enabled": false, "at": "05:30", "timezone": "UTC"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updater.enabled || updater.cron != "30 5 * * *" || updater.timezone != "UTC" {
+		t.Errorf("unexpected config %+v", updater)
+	}
+	if jobs := (&horoscope{updater: updater}).Jobs(); len(jobs) != 0 {
+		t.Errorf("the daily update was scheduled while it is turned off: %+v", jobs)
+	}
+
+	updater, err = configureHoroscopeUpdater([]byte(`{"horoscope": {"update": {"cron": "0 6 * * mon"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updater.cron != "0 6 * * mon" {
+		t.Errorf("unexpected cron %q", updater.cron)
+	}
+
+	for _, config := range []string{
+		`{"horoscope": {"update": {"at": "4am"}}}`,
+		`{"horoscope": {"update": {"timezone": "Moon/Base"}}}`,
+		`{"horoscope": {"update": {"cron": "0 25 * * *"}}}`,
+	} {
+		if _, err := configureHoroscopeUpdater([]byte(config)); err == nil {
+			t.Errorf("config %v was accepted", config)
+		}
+	}
+}
+
+func TestUpdateAllHoroscopeData(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sign := strings.Split(r.URL.Path, "/")[2]
+		fmt.Fprintf(w, `{"date": "2026-06-20", "sunsign": %q, "horoscope": "Stars of %v", "meta": {"intensity": "50%%", "keywords": "sauna", "mood": "calm"}}`, sign, sign)
+	}))
+	defer server.Close()
+
+	for _, sign := range allHoroscopeSigns() {
+		expectation := mock.ExpectExec("^UPDATE horoscope").WithArgs("2026-06-20", "Stars of "+sign.String(), "50%", "sauna", "calm", sign.String())
+		if sign != horoscopeSignPisces {
+			expectation.WillReturnResult(sqlmock.NewResult(0, 1))
+			continue
+		}
+		// the row of a sign is added if the table doesn't have it
+		expectation.WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^INSERT INTO horoscope").WithArgs("2026-06-20", "pisces", "Stars of pisces", "50%", "sauna", "calm").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	if err := updateAllHoroscopeData(context.Background(), db, server.Client(), server.URL+"/horoscope/{sign}/today"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateAllHoroscopeDataFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "asleep", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err = updateAllHoroscopeData(context.Background(), db, server.Client(), server.URL+"/{sign}")
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected the status of the API in the error, got %v", err)
+	}
+	// nothing is written to the database
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestHoroscopeUpdateGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "asleep", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	h := &horoscope{updater: horoscopeUpdater{url: server.URL + "/{sign}"}}
+	bot := &Bot{httpClient: server.Client(), stats: newRunnerStats()}
+
+	// the next update is sooner than the first retry
+	if err := h.update(context.Background(), bot, time.Now().Add(time.Second)); err == nil {
+		t.Error("a failed update returned no error")
+	}
+
+	status := bot.stats.horoscope.snapshot()
+	if status.Failures != 1 || status.LastError == "" || status.LastAttempt.IsZero() || !status.LastSuccess.IsZero() {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestHoroscopeUpdatesOnce(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "asleep", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	h := &horoscope{updater: horoscopeUpdater{enabled: true, onStart: true, url: server.URL + "/{sign}"}}
+	bot := &Bot{httpClient: server.Client(), stats: newRunnerStats()}
+	ctx := context.Background()
+
+	// the update at start ran after the missed daily update was due
+	due := time.Now().Add(-time.Hour)
+	bot.stats.horoscope.record(time.Now(), nil)
+	if err := h.RunJob(ctx, bot, Job{Name: horoscopeUpdateJob, Next: due}); err != nil {
+		t.Fatal(err)
+	}
+	h.Work(ctx, bot)
+	if requests != 0 {
+		t.Errorf("horoscopes that were up to date were fetched %v times", requests)
+	}
+}
+
+func TestHoroscopeStatusCommand(t *testing.T) {
+	m := &recordingMessenger{admins: map[int64]bool{}}
+	h := &horoscope{triggerWords: []string{"/horoscope"}, updater: horoscopeUpdater{enabled: true, cron: "0 4 * * *", timezone: "UTC"}}
+	bot := &Bot{messenger: m, cfg: &Config{}, features: []Feature{h}, schedule: newSchedule(newMemoryJobStore(), defaultLogger), stats: newRunnerStats()}
+	ctx := context.Background()
+
+	group := commandUpdate("/horoscope status")
+	group.Message.Chat.Type = "group"
+
+	success := time.Date(2026, 6, 20, 4, 0, 0, 0, time.UTC)
+	bot.stats.horoscope.record(success, nil)
+	bot.stats.horoscope.record(success.Add(24*time.Hour), errors.New("API down"))
+	bot.schedule.register(bot)
+	next := h.nextUpdate(bot, time.Now()).Format(horoscopeTimeFormat)
+
+	for _, u := range []Update{group, commandUpdate("/horoscope Status")} {
+		if _, err := h.Execute(ctx, bot, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		"Only chat administrators can see the status of the horoscopes",
+		"Horoscopes were last updated at 2026-06-20 04:00 UTC.\n" +
+			"The last 1 attempts failed: API down\n" +
+			"Next update at " + next + ".",
+	}
+	if len(m.replies) != len(expected) {
+		t.Fatalf("expected %v replies, got %+v", len(expected), m.replies)
+	}
+	for i, reply := range m.replies {
+		if reply.Text != expected[i] {
+			t.Errorf("expected reply %q, got %q", expected[i], reply.Text)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package jbot
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	gjson "github.com/tidwall/gjson"
+)
+
+const defaultInlineCacheSize = 1000
+
+// InlineAnswerer can be implemented by features that answer inline
+// queries. A query such as "@juhannusbot decide sauna lake" is passed
+// to the feature that owns the command "/decide", with the command
+// parsed into Update.Command like for messages.
+type InlineAnswerer interface {
+	AnswerInline(ctx context.Context, bot *Bot, u Update) (InlineAnswer, error)
+}
+
+// inline is a feature that answers inline queries with the help
+// of the features that own the commands in the queries. Answers
+// are cached for as long as the features allow.
+type inline struct {
+	cache *inlineCache
+}
+
+func (i *inline) String() string {
+	return "inline"
+}
+
+func (i *inline) Init(bot *Bot) error {
+	size := defaultInlineCacheSize
+	if jsonSize := gjson.GetBytes(bot.cfg.Features, "inline.cachesize"); jsonSize.Exists() {
+		size = int(jsonSize.Int())
+	}
+	i.cache = newInlineCache(size)
+	return nil
+}
+
+func (i *inline) Kinds() []UpdateKind {
+	return []UpdateKind{KindInlineQuery}
+}
+
+func (i *inline) Triggers(u Update) bool {
+	return u.Inline != nil
+}
+
+// Execute answers the inline query of u. Queries that no running
+// feature can answer get an empty answer, so that the user
+// doesn't wait for results that never come.
+func (i *inline) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	if u.Inline == nil {
+		return false, nil
+	}
+
+	answer, err := i.answer(ctx, bot, u)
+	if err != nil {
+		return true, err
+	}
+	return true, bot.messenger.answerInline(ctx, u.Inline.ID, answer)
+}
+
+// answer returns the answer to the query of u from the cache
+// or from the feature that owns the command of the query.
+func (i *inline) answer(ctx context.Context, bot *Bot, u Update) (InlineAnswer, error) {
+	cmd, owner, ok := routeInline(bot, u)
+	if !ok || !featureRunsInChat(ctx, bot, u.ChatID(), owner.String()) {
+		return InlineAnswer{}, nil
+	}
+	answerer, ok := inlineAnswererOf(owner)
+	if !ok {
+		return InlineAnswer{}, nil
+	}
+
+	key := bot.Language(ctx, u) + "\x00" + owner.String() + "\x00" + strings.Join(append([]string{cmd.Name}, cmd.Args...), "\x00")
+	if answer, ok := i.cache.get(key); ok {
+		return answer, nil
+	}
+
+	u.Command = cmd
+	answer, err := answerer.AnswerInline(ctx, bot, u)
+	if err != nil {
+		return InlineAnswer{}, err
+	}
+	i.cache.put(key, answer)
+	return answer, nil
+}
+
+// routeInline parses the query of u and returns the feature that owns it.
+// The command of a query may be written with or without the slash.
+func routeInline(bot *Bot, u Update) (*Command, Feature, bool) {
+	cmd, ok := parseCommand(u.Inline.Query)
+	if !ok {
+		return nil, nil, false
+	}
+
+	for _, name := range []string{cmd.Name, "/" + cmd.Name} {
+		if owner, ok := bot.commands[name]; ok {
+			cmd.Name = name
+			return &cmd, owner, true
+		}
+	}
+	return nil, nil, false
+}
+
+// inlineAnswererOf returns the InlineAnswerer of feat, looking through middlewares.
+func inlineAnswererOf(feat Feature) (InlineAnswerer, bool) {
+	for _, f := range featureChain(feat) {
+		if answerer, ok := f.(InlineAnswerer); ok {
+			return answerer, true
+		}
+	}
+	return nil, false
+}
+
+// inlineCache keeps the answers to the last inline queries until they expire.
+type inlineCache struct {
+	size int // max number of answers, non-positive for no cache
+
+	mu      sync.Mutex
+	answers map[string]cachedAnswer
+	order   []string // the keys of answers in the order they were added
+}
+
+type cachedAnswer struct {
+	answer  InlineAnswer
+	expires time.Time
+}
+
+func newInlineCache(size int) *inlineCache {
+	return &inlineCache{size: size, answers: make(map[string]cachedAnswer)}
+}
+
+// get returns the answer for key if it has not expired.
+func (c *inlineCache) get(key string) (InlineAnswer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.answers[key]
+	if !ok || time.Now().After(cached.expires) {
+		return InlineAnswer{}, false
+	}
+	return cached.answer, true
+}
+
+// put caches answer for key for the cache time of answer.
+// The oldest answer is dropped when the cache is full.
+func (c *inlineCache) put(key string, answer InlineAnswer) {
+	if c.size <= 0 || answer.CacheTime <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.answers[key]; !ok {
+		c.order = append(c.order, key)
+		if len(c.order) > c.size {
+			delete(c.answers, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	c.answers[key] = cachedAnswer{answer, time.Now().Add(answer.CacheTime)}
+}
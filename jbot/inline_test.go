@@ -0,0 +1,105 @@
+package jbot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// inlineFeature is a fake feature that owns commands and answers
+// inline queries with its arguments.
+type inlineFeature struct {
+	fakeFeature
+	commands  []string
+	cacheTime time.Duration
+	answered  int
+}
+
+func (f *inlineFeature) Commands() []string {
+	return f.commands
+}
+
+func (f *inlineFeature) AnswerInline(ctx context.Context, bot *Bot, u Update) (InlineAnswer, error) {
+	f.answered++
+	results := []InlineResult{}
+	for _, arg := range u.Command.Args {
+		results = append(results, InlineResult{Title: arg, Text: arg})
+	}
+	return InlineAnswer{Results: results, CacheTime: f.cacheTime}, nil
+}
+
+func inlineUpdate(query string) Update {
+	return Update{ID: 1, Kind: KindInlineQuery, Inline: &Inline{ID: "q", Query: query, Sender: &User{ID: 100}}}
+}
+
+func TestInlineRoutesQueries(t *testing.T) {
+	m := &recordingMessenger{}
+	echo := &inlineFeature{fakeFeature: fakeFeature{name: "echo"}, commands: []string{"/echo"}, cacheTime: time.Minute}
+	plain := &fakeFeature{name: "plain", trigger: true}
+	i := &inline{cache: newInlineCache(10)}
+	features := []Feature{i, echo, plain}
+	bot := &Bot{messenger: m, features: features, commands: newCommandTable(features)}
+
+	for _, query := range []string{"echo a b", "/echo a b", "echo c", "unknown a", ""} {
+		dispatch(context.Background(), bot, inlineUpdate(query))
+	}
+
+	if len(m.inline) != 5 {
+		t.Fatalf("expected 5 answers, got %v", len(m.inline))
+	}
+	if len(m.inline[0].Results) != 2 || m.inline[0].Results[1].Text != "b" || len(m.inline[2].Results) != 1 {
+		t.Errorf("the queries were not answered by the feature: %+v", m.inline)
+	}
+	if len(m.inline[3].Results) != 0 || len(m.inline[4].Results) != 0 {
+		t.Errorf("a query without a feature got results: %+v", m.inline[3:])
+	}
+	if echo.answered != 2 {
+		t.Errorf("expected the same query to be answered from the cache, the feature answered %v times", echo.answered)
+	}
+	if len(plain.executed) != 0 {
+		t.Error("a feature that does not handle inline queries saw one")
+	}
+}
+
+func TestInlineCache(t *testing.T) {
+	c := newInlineCache(2)
+
+	c.put("never", InlineAnswer{})
+	if _, ok := c.get("never"); ok {
+		t.Error("an answer without a cache time was cached")
+	}
+
+	c.put("a", InlineAnswer{CacheTime: time.Minute})
+	c.put("b", InlineAnswer{CacheTime: time.Minute})
+	c.put("c", InlineAnswer{CacheTime: time.Minute})
+	if _, ok := c.get("a"); ok {
+		t.Error("the oldest answer was not dropped from a full cache")
+	}
+	if _, ok := c.get("c"); !ok {
+		t.Error("the newest answer was not cached")
+	}
+
+	c.answers["c"] = cachedAnswer{InlineAnswer{}, time.Now().Add(-time.Second)}
+	if _, ok := c.get("c"); ok {
+		t.Error("an expired answer was returned")
+	}
+}
+
+func TestDecideAnswerInline(t *testing.T) {
+	bot := &Bot{}
+	u := inlineUpdate("decide sauna sauna")
+	u.Command = &Command{Name: "/decide", Args: []string{"sauna", "sauna"}}
+
+	answer, err := (&decide{}).AnswerInline(context.Background(), bot, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answer.Results) != 1 || answer.Results[0].Text != "sauna, sauna? sauna" || answer.CacheTime != 0 {
+		t.Errorf("unexpected answer %+v", answer)
+	}
+
+	u.Command.Args = []string{"sauna"}
+	if answer, _ := (&decide{}).AnswerInline(context.Background(), bot, u); len(answer.Results) != 0 {
+		t.Errorf("a single option got results: %+v", answer)
+	}
+}
@@ -2,31 +2,64 @@
 package jbot
 
 import (
+	"context"
 	"database/sql"
-	"log"
-	"math/rand"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
 	"time"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	_ "github.com/lib/pq" // blank import to use PostgreSQL
 )
 
-// bot is a collection of relevant pointers.
-type jbot struct {
-	botAPI   *tgbotapi.BotAPI
-	database *sql.DB
-	cfg      *config
+// Bot is a collection of relevant pointers. It gives features access
+// to the config, the database, the HTTP client, the log and the chats.
+// A Bot is not modified after its features have been initialised.
+type Bot struct {
+	messenger  messenger
+	database   *sql.DB
+	httpClient *http.Client
+	logger     *Logger
+	cfg        *Config
+	features   []Feature       // running features in the order they see updates
+	toggles    *featureToggles // per chat toggles, nil if the toggles feature is not running
+	languages  *chatLanguages  // per chat languages, nil if the language feature is not running
+	catalog    catalog         // translations of the texts of the bot
+	replies    *replyLog       // replies to commands, nil unless edited commands run again
+	schedule   *schedule       // jobs of the features
+	panics     *panicGuard
+	stats      *runnerStats // counters of the Runner, nil counts nothing
+	rand       *random
+	failed     map[string]error          // errors of the features that failed to initialise
+	retry      map[string]func() Feature // constructors of the failed features that are unhealthy and retried
+	commands   commandTable
+	username   string // username of the bot, empty if not known
 }
 
-// feature is an interface that all of the bots features must satisfy
-type feature interface {
-	init(*jbot) error
-	triggers(tgbotapi.Update) bool
-	execute(*jbot, tgbotapi.Update) error
+// Feature is an interface that all of the bots features must satisfy.
+// Init is called when the features are initialised, Triggers checks if
+// an update triggers the feature and Execute is called if it did.
+// Execute returns true if it handled the update, which stops features
+// with a lower priority from seeing it. String returns the name of the feature.
+type Feature interface {
+	Init(*Bot) error
+	Triggers(Update) bool
+	Execute(context.Context, *Bot, Update) (bool, error)
 	String() string
 }
 
-// Start starts and runs the bot.
+const defaultShutdownTimeout = 10 * time.Second
+
+// cancelGracePeriod is how long features that were cancelled at shutdown
+// get to return before the bot stops, and the database is closed under them.
+const cancelGracePeriod = 2 * time.Second
+
+// Start starts and runs the bot with the config.json in the current
+// working directory until the process receives SIGINT or SIGTERM.
+// When the process receives SIGHUP, config.json is reloaded.
 func Start() error {
 
 	cfg, err := configure()
@@ -34,60 +67,177 @@ func Start() error {
 		return err
 	}
 
-	botAPI, err := tgbotapi.NewBotAPI(cfg.APIKey)
+	return runUntilSignal(Options{Config: cfg}, configure)
+}
+
+// StartConsole is like Start but runs the bot in a terminal instead of
+// telegram. Lines read from in are handled as messages to the bot
+// and the replies of the bot are written to out.
+func StartConsole(in io.Reader, out io.Writer) error {
+
+	load := func() (Config, error) {
+		return LoadConfig(configFileName)
+	}
+
+	cfg, err := load()
 	if err != nil {
 		return err
 	}
-	log.Printf("Telegram botAPI authenticated for %v", botAPI.Self.UserName)
 
-	botAPIUpdateConfig := tgbotapi.NewUpdate(0)
-	botAPIUpdateConfig.Timeout = 60
+	return runUntilSignal(Options{Config: cfg, ConsoleIn: in, ConsoleOut: out}, load)
+}
 
-	updates, err := botAPI.GetUpdatesChan(botAPIUpdateConfig)
+// runUntilSignal creates a Runner from opts and runs it until the process
+// receives SIGINT or SIGTERM. On SIGHUP, the config is reloaded with load.
+func runUntilSignal(opts Options, load func() (Config, error)) error {
+
+	r, err := New(opts)
 	if err != nil {
 		return err
 	}
+	defer r.Close()
 
-	db, err := sql.Open("postgres", cfg.DatabaseURL)
-	if err != nil {
-		return err
+	ctx, stop := contextWithSignals(context.Background(), r.logger, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go onSignal(ctx, r.logger, func() {
+		cfg, err := load()
+		if err == nil {
+			err = r.Reload(cfg)
+		}
+		if err != nil {
+			r.logger.Error("not reloading config", "error", err)
+		}
+	}, syscall.SIGHUP)
+
+	return r.Run(ctx)
+}
+
+// dispatch executes the features of bot that u triggers in order
+// until one of them reports that it handled u. Features only see the
+// kinds of updates they handle. A command is routed to the feature
+// that owns it before the other features see it. Panics in features
+// are recovered and handled like errors. The log lines about u carry
+// its update, chat and user.
+func dispatch(ctx context.Context, bot *Bot, u Update) {
+	kind := u.kind()
+
+	logger := updateLogger(bot.Log(ctx), u)
+	ctx = withLogger(ctx, logger)
+	start := time.Now()
+	defer func() {
+		logger.Debug("update handled", "latency", time.Since(start))
+	}()
+
+	ownerName := ""
+	if cmd, owner := bot.commands.route(u, bot.username); owner != nil && (handlesKind(owner, kind) || rerunsCommand(bot, kind)) {
+		u.Command = cmd
+		ownerName = owner.String()
+		if executeCommand(ctx, bot, owner, u, kind) {
+			return
+		}
 	}
-	defer db.Close()
 
-	if connected(db) {
-		log.Println("connected to database")
-	} else {
-		log.Println("no database connection")
+	for _, feat := range bot.features {
+		if feat.String() != ownerName && handlesKind(feat, kind) && execute(ctx, bot, feat, u, true) {
+			return
+		}
 	}
+}
 
-	rand.Seed(time.Now().UnixNano())
+// rerunsCommand returns true if commands in updates of kind run again
+// and edit their earlier replies.
+func rerunsCommand(bot *Bot, kind UpdateKind) bool {
+	return bot.replies != nil && kind == KindEditedMessage
+}
 
-	mybot := jbot{botAPI, db, &cfg}
+// executeCommand executes owner for the command of u. If edited commands
+// run again, the replies to the command are remembered, and the replies
+// to an edited command edit the ones of the earlier version.
+func executeCommand(ctx context.Context, bot *Bot, owner Feature, u Update, kind UpdateKind) bool {
+	if bot.replies == nil || (kind != KindMessage && kind != KindEditedMessage) {
+		return execute(ctx, bot, owner, u, false)
+	}
 
-	allFeatures := []feature{
-		new(decide),
-		new(pingpong),
-		new(horoscope),
-		new(wisdom),
+	origin := &replyOrigin{key: replyKey{u.ChatID(), u.Message.ID}, log: bot.replies}
+	if kind == KindEditedMessage {
+		origin.previous = bot.replies.get(origin.key)
 	}
-	features := []feature{}
-
-	for _, feat := range allFeatures {
-		if err = feat.init(&mybot); err != nil {
-			log.Printf("not running %v: %v", feat.String(), err)
-		} else {
-			features = append(features, feat)
-			log.Printf("running %v", feat.String())
-		}
+	return execute(withReplyOrigin(ctx, origin), bot, owner, u, false)
+}
+
+// execute executes feat for u if it is enabled and, when checkTriggers
+// is true, u triggers it. The Triggers of the middlewares of feat are
+// asked also when checkTriggers is false. It returns true if feat handled u. The log
+// lines of feat carry its name and how long it took to execute u.
+func execute(ctx context.Context, bot *Bot, feat Feature, u Update, checkTriggers bool) bool {
+	name := feat.String()
+	if bot.panics.isDisabled(name) {
+		return false
+	}
+	if bot.toggles != nil && !bot.toggles.enabled(ctx, u.ChatID(), name) {
+		return false
 	}
 
-	for update := range updates {
-		for _, feat := range features {
-			if feat.triggers(update) {
-				feat.execute(&mybot, update)
-			}
+	logger := bot.Log(ctx).With("feature", name)
+	ctx = withLogger(ctx, logger)
+
+	// the middlewares of a feature decide also about its commands
+	if _, wrapped := feat.(Wrapper); !checkTriggers && wrapped {
+		u.routed, checkTriggers = true, true
+	}
+	if checkTriggers {
+		triggered, err := callTriggers(feat, u)
+		if err != nil {
+			bot.panics.record(name, err)
+			handleFeatureError(ctx, bot, name, u, err)
+			return false
+		}
+		if !triggered {
+			return false
 		}
 	}
 
-	return nil
+	start := time.Now()
+	handled, err := callExecute(ctx, bot, feat, u)
+	latency := time.Since(start)
+	bot.panics.record(name, err)
+	if err != nil {
+		handleFeatureError(withLogger(ctx, logger.With("latency", latency)), bot, name, u, err)
+	} else {
+		logger.Debug("feature executed", "handled", handled, "latency", latency)
+	}
+	return handled
+}
+
+// sortByPriority sorts features from the highest priority to the lowest.
+// Features missing from priorities have priority 0.
+func sortByPriority(features []Feature, priorities map[string]int) {
+	sort.SliceStable(features, func(i, j int) bool {
+		return priorities[features[i].String()] > priorities[features[j].String()]
+	})
+}
+
+// contextWithSignals returns a copy of parent that is cancelled
+// when one of the signals is received or when stop is called.
+// Received signals are logged to logger.
+func contextWithSignals(parent context.Context, logger *Logger, signals ...os.Signal) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+
+	go func() {
+		select {
+		case s := <-received:
+			logger.Info("received signal", "signal", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(received)
+		cancel()
+	}
 }
@@ -1,6 +1,8 @@
 package jbot
 
 import (
+	"context"
+	"sync"
 	"testing"
 )
 
@@ -8,3 +10,117 @@ func TestStart(t *testing.T) {
 	// To test this function properly, a mock telegram bot API is needed.
 	t.Skipf("Start test skipped for now")
 }
+
+// recordingMessenger is a messenger that remembers
+// everything that was sent with it.
+type recordingMessenger struct {
+	mu        sync.Mutex
+	replies   []Reply
+	callbacks []string
+	inline    []InlineAnswer
+	admins    map[int64]bool // users that are administrators of every chat
+}
+
+func (m *recordingMessenger) send(ctx context.Context, r Reply) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.replies = append(m.replies, r)
+	return len(m.replies), nil
+}
+
+func (m *recordingMessenger) answerCallback(ctx context.Context, callbackID string, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callbacks = append(m.callbacks, callbackID)
+	return nil
+}
+
+func (m *recordingMessenger) answerInline(ctx context.Context, queryID string, answer InlineAnswer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inline = append(m.inline, answer)
+	return nil
+}
+
+func (m *recordingMessenger) isAdmin(ctx context.Context, chatID int64, userID int64) (bool, error) {
+	return m.admins[userID], nil
+}
+
+// textUpdate returns an update with a message containing text.
+func textUpdate(text string) Update {
+	return Update{
+		ID: 1,
+		Message: &Message{
+			ID:     10,
+			Text:   text,
+			Sender: &User{ID: 100, FirstName: "Test"},
+			Chat:   Chat{ID: 1000, Type: "private"},
+		},
+	}
+}
+
+// commandUpdate returns a message update with text parsed as a command,
+// like dispatch does for commands that a feature owns.
+func commandUpdate(text string) Update {
+	u := textUpdate(text)
+	cmd, _ := parseCommand(text)
+	u.Command = &cmd
+	return u
+}
+
+// fakeFeature is a feature that records the updates it executes.
+type fakeFeature struct {
+	name     string
+	trigger  bool
+	handle   bool
+	err      error
+	executed []Update
+}
+
+func (f *fakeFeature) String() string         { return f.name }
+func (f *fakeFeature) Init(bot *Bot) error    { return nil }
+func (f *fakeFeature) Triggers(u Update) bool { return f.trigger }
+
+func (f *fakeFeature) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	f.executed = append(f.executed, u)
+	return f.handle, f.err
+}
+
+func TestDispatchStopsWhenHandled(t *testing.T) {
+	notTriggered := &fakeFeature{name: "a", trigger: false, handle: true}
+	passes := &fakeFeature{name: "b", trigger: true, handle: false}
+	handles := &fakeFeature{name: "c", trigger: true, handle: true}
+	tooLate := &fakeFeature{name: "d", trigger: true, handle: true}
+
+	bot := &Bot{features: []Feature{notTriggered, passes, handles, tooLate}}
+	dispatch(context.Background(), bot, textUpdate("hello"))
+
+	if len(notTriggered.executed) != 0 {
+		t.Error("a feature that did not trigger was executed")
+	}
+	if len(passes.executed) != 1 || len(handles.executed) != 1 {
+		t.Error("a triggered feature was not executed")
+	}
+	if len(tooLate.executed) != 0 {
+		t.Error("a feature was executed after the update was handled")
+	}
+}
+
+func TestSortByPriority(t *testing.T) {
+	features := []Feature{
+		&fakeFeature{name: "pingpong"},
+		&fakeFeature{name: "decide"},
+		&fakeFeature{name: "wisdom"},
+		&fakeFeature{name: "horoscope"},
+	}
+	priorities := map[string]int{"decide": 10, "horoscope": 5, "pingpong": -1}
+
+	sortByPriority(features, priorities)
+
+	expected := []string{"decide", "horoscope", "wisdom", "pingpong"}
+	for i, feat := range features {
+		if feat.String() != expected[i] {
+			t.Fatalf("expected order %v, got %v at index %v", expected, feat.String(), i)
+		}
+	}
+}
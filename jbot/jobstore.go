@@ -0,0 +1,249 @@
+package jbot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// jobStore keeps the scheduled jobs. Every job is stored with the time
+// it runs next. String describes where the jobs are kept.
+type jobStore interface {
+	// put adds job or replaces the job with the same name.
+	put(ctx context.Context, job Job) error
+	// remove removes the job called name of feature, if there is one.
+	remove(ctx context.Context, feature string, name string) error
+	// get returns the job called name.
+	get(ctx context.Context, name string) (Job, bool, error)
+	// list returns the jobs of feature in the order they run.
+	list(ctx context.Context, feature string) ([]Job, error)
+	// declared returns the jobs of Schedulers in the order they run.
+	declared(ctx context.Context) ([]Job, error)
+	// due returns the jobs that should have run by now, oldest first.
+	due(ctx context.Context, now time.Time) ([]Job, error)
+	// earliest returns the first time a job runs after now.
+	earliest(ctx context.Context, now time.Time) (time.Time, bool, error)
+	// claim moves job from job.Next to next, or removes it if next is
+	// zero. It returns false if the job was changed or claimed since it
+	// was read, so that only one claim of a run succeeds.
+	claim(ctx context.Context, job Job, next time.Time) (bool, error)
+	String() string
+}
+
+// newJobStore returns a store that uses the scheduled_job table if the
+// database has it, and one that keeps the jobs in memory otherwise.
+// A database without the table is logged as an error, because the
+// jobs are then lost when the bot stops.
+func newJobStore(db *sql.DB, logger *Logger) jobStore {
+	if !connected(db) {
+		return newMemoryJobStore()
+	}
+
+	var tableExists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT " + jobColumns + " FROM scheduled_job)").Scan(&tableExists)
+	if err != nil {
+		logger.Error("can't store scheduled jobs in the database, keeping them in memory", "error", err)
+		return newMemoryJobStore()
+	}
+	return &dbJobStore{db}
+}
+
+// dbJobStore stores the jobs in the scheduled_job table.
+type dbJobStore struct {
+	db *sql.DB
+}
+
+const jobColumns = "name, feature, cron, timezone, chat_id, data, next_run, declared"
+
+func (s *dbJobStore) put(ctx context.Context, job Job) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO scheduled_job ("+jobColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8) "+
+		"ON CONFLICT (name) DO UPDATE SET feature = $2, cron = $3, timezone = $4, chat_id = $5, data = $6, next_run = $7, declared = $8",
+		job.Name, job.Feature, job.Cron, job.Timezone, job.ChatID, job.Data, job.Next, job.declared)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	return nil
+}
+
+func (s *dbJobStore) remove(ctx context.Context, feature string, name string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM scheduled_job WHERE name = $1 AND feature = $2", name, feature)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	return nil
+}
+
+func (s *dbJobStore) get(ctx context.Context, name string) (Job, bool, error) {
+	jobs, err := s.query(ctx, "SELECT "+jobColumns+" FROM scheduled_job WHERE name = $1", name)
+	if err != nil || len(jobs) == 0 {
+		return Job{}, false, err
+	}
+	return jobs[0], true, nil
+}
+
+func (s *dbJobStore) list(ctx context.Context, feature string) ([]Job, error) {
+	return s.query(ctx, "SELECT "+jobColumns+" FROM scheduled_job WHERE feature = $1 ORDER BY next_run", feature)
+}
+
+func (s *dbJobStore) declared(ctx context.Context) ([]Job, error) {
+	return s.query(ctx, "SELECT "+jobColumns+" FROM scheduled_job WHERE declared ORDER BY next_run")
+}
+
+func (s *dbJobStore) due(ctx context.Context, now time.Time) ([]Job, error) {
+	return s.query(ctx, "SELECT "+jobColumns+" FROM scheduled_job WHERE next_run <= $1 ORDER BY next_run", now)
+}
+
+func (s *dbJobStore) earliest(ctx context.Context, now time.Time) (time.Time, bool, error) {
+	var next time.Time
+	err := s.db.QueryRowContext(ctx, "SELECT next_run FROM scheduled_job WHERE next_run > $1 ORDER BY next_run LIMIT 1", now).Scan(&next)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("database error: %v", err)
+	}
+	return next, true, nil
+}
+
+func (s *dbJobStore) claim(ctx context.Context, job Job, next time.Time) (bool, error) {
+	var result sql.Result
+	var err error
+	if next.IsZero() {
+		result, err = s.db.ExecContext(ctx, "DELETE FROM scheduled_job WHERE name = $1 AND next_run = $2", job.Name, job.Next)
+	} else {
+		result, err = s.db.ExecContext(ctx, "UPDATE scheduled_job SET next_run = $3 WHERE name = $1 AND next_run = $2", job.Name, job.Next, next)
+	}
+	if err != nil {
+		return false, fmt.Errorf("database error: %v", err)
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("database error: %v", err)
+	}
+	return claimed == 1, nil
+}
+
+// query returns the jobs that query selects with args.
+func (s *dbJobStore) query(ctx context.Context, query string, args ...interface{}) ([]Job, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		var job Job
+		if err = rows.Scan(&job.Name, &job.Feature, &job.Cron, &job.Timezone, &job.ChatID, &job.Data, &job.Next, &job.declared); err != nil {
+			return nil, fmt.Errorf("database error: %v", err)
+		}
+		if job.Cron == "" {
+			job.At = job.Next
+		}
+		jobs = append(jobs, job)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return jobs, nil
+}
+
+func (s *dbJobStore) String() string {
+	return "database"
+}
+
+// memoryJobStore keeps the jobs in memory, so they are lost when the bot stops.
+type memoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+func newMemoryJobStore() *memoryJobStore {
+	return &memoryJobStore{jobs: make(map[string]Job)}
+}
+
+func (s *memoryJobStore) put(ctx context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.Name] = job
+	return nil
+}
+
+func (s *memoryJobStore) remove(ctx context.Context, feature string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.jobs[name].Feature == feature {
+		delete(s.jobs, name)
+	}
+	return nil
+}
+
+func (s *memoryJobStore) get(ctx context.Context, name string) (Job, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[name]
+	return job, ok, nil
+}
+
+func (s *memoryJobStore) list(ctx context.Context, feature string) ([]Job, error) {
+	return s.filter(func(job Job) bool { return job.Feature == feature }), nil
+}
+
+func (s *memoryJobStore) declared(ctx context.Context) ([]Job, error) {
+	return s.filter(func(job Job) bool { return job.declared }), nil
+}
+
+func (s *memoryJobStore) due(ctx context.Context, now time.Time) ([]Job, error) {
+	return s.filter(func(job Job) bool { return !job.Next.After(now) }), nil
+}
+
+func (s *memoryJobStore) earliest(ctx context.Context, now time.Time) (time.Time, bool, error) {
+	later := s.filter(func(job Job) bool { return job.Next.After(now) })
+	if len(later) == 0 {
+		return time.Time{}, false, nil
+	}
+	return later[0].Next, true, nil
+}
+
+func (s *memoryJobStore) claim(ctx context.Context, job Job, next time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.jobs[job.Name]
+	if !ok || !stored.Next.Equal(job.Next) {
+		return false, nil
+	}
+	if next.IsZero() {
+		delete(s.jobs, job.Name)
+	} else {
+		stored.Next = next
+		s.jobs[job.Name] = stored
+	}
+	return true, nil
+}
+
+// filter returns the jobs for which keep returns true in the order they run.
+func (s *memoryJobStore) filter(keep func(Job) bool) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []Job{}
+	for _, job := range s.jobs {
+		if keep(job) {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Next.Before(jobs[j].Next) })
+	return jobs
+}
+
+func (s *memoryJobStore) String() string {
+	return "memory"
+}
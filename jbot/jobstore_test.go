@@ -0,0 +1,91 @@
+package jbot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDBJobStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	next := time.Date(2026, 6, 20, 9, 0, 0, 0, time.UTC)
+	job := Job{Name: "reminder.1", Feature: "reminder", ChatID: 1000, Data: "sauna", At: next, Next: next}
+	columns := []string{"name", "feature", "cron", "timezone", "chat_id", "data", "next_run", "declared"}
+
+	mock.ExpectQuery("^SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("^INSERT INTO scheduled_job").WithArgs("reminder.1", "reminder", "", "", 1000, "sauna", next, false).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("^SELECT name, feature, cron, timezone, chat_id, data, next_run, declared FROM scheduled_job WHERE next_run <=").WithArgs(next).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("reminder.1", "reminder", "", "", 1000, "sauna", next, false))
+	mock.ExpectQuery("^SELECT name, feature, cron, timezone, chat_id, data, next_run, declared FROM scheduled_job WHERE declared").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("horoscope.update", "horoscope", "0 4 * * *", "", 0, "", next, true))
+	mock.ExpectExec("^DELETE FROM scheduled_job WHERE name = \\$1 AND next_run").WithArgs("reminder.1", next).WillReturnResult(sqlmock.NewResult(0, 1))
+	// another process claimed the run first
+	mock.ExpectExec("^DELETE FROM scheduled_job WHERE name = \\$1 AND next_run").WithArgs("reminder.1", next).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^UPDATE scheduled_job SET next_run").WithArgs("daily", next, next.Add(24*time.Hour)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	store := newJobStore(db, defaultLogger)
+	if store.String() != "database" {
+		t.Fatalf("expected the jobs in the database, got %v", store)
+	}
+	ctx := context.Background()
+
+	if err := store.put(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	due, err := store.due(ctx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0] != job {
+		t.Fatalf("expected %+v to be due, got %+v", job, due)
+	}
+	declared, err := store.declared(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(declared) != 1 || declared[0].Name != "horoscope.update" || !declared[0].declared {
+		t.Fatalf("unexpected declared jobs %+v", declared)
+	}
+	for _, expected := range []bool{true, false} {
+		claimed, err := store.claim(ctx, job, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claimed != expected {
+			t.Errorf("expected the claim to return %v", expected)
+		}
+	}
+	if claimed, err := store.claim(ctx, Job{Name: "daily", Cron: "0 9 * * *", Next: next}, next.Add(24*time.Hour)); err != nil || !claimed {
+		t.Errorf("failed to claim a repeating job: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestNewJobStoreWithoutTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT EXISTS").WillReturnError(errors.New(`relation "scheduled_job" does not exist`))
+
+	l, buf := bufferLogger(LogConfig{Format: "json"})
+	if store := newJobStore(db, l); store.String() != "memory" {
+		t.Errorf("expected the jobs in memory without the table, got %v", store)
+	}
+	if lines := logLines(t, buf); len(lines) != 1 || lines[0]["level"] != "error" {
+		t.Errorf("the missing table was not logged as an error: %v", lines)
+	}
+}
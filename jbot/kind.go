@@ -0,0 +1,63 @@
+package jbot
+
+// UpdateKind tells what happened in an update.
+type UpdateKind string
+
+const (
+	KindMessage           UpdateKind = "message"             // new message, in Update.Message
+	KindEditedMessage     UpdateKind = "edited_message"      // edited message, in Update.Message
+	KindChannelPost       UpdateKind = "channel_post"        // new post of a channel, in Update.Message
+	KindEditedChannelPost UpdateKind = "edited_channel_post" // edited post of a channel, in Update.Message
+	KindCallback          UpdateKind = "callback"            // pressed button, in Update.Callback
+	KindMembersJoined     UpdateKind = "members_joined"      // users joined a chat, in Update.Message.NewMembers
+	KindMemberLeft        UpdateKind = "member_left"         // a user left a chat, in Update.Message.LeftMember
+	KindBotMember         UpdateKind = "bot_member"          // the bot was added to, removed from or promoted in a chat, in Update.BotMember
+	KindInlineQuery       UpdateKind = "inline_query"        // a user typed "@bot query", in Update.Inline
+	KindOther             UpdateKind = "other"               // anything else
+)
+
+// defaultKinds are the kinds of updates that features
+// see if they don't implement KindHandler.
+var defaultKinds = []UpdateKind{KindMessage, KindCallback}
+
+// KindHandler can be implemented by features that want to see other
+// kinds of updates than new messages and callbacks. Kinds returns
+// the kinds of updates that the feature sees.
+type KindHandler interface {
+	Kinds() []UpdateKind
+}
+
+// kind returns the kind of u. Updates that were made without
+// a kind are messages or callbacks, depending on what they hold.
+func (u Update) kind() UpdateKind {
+	switch {
+	case u.Kind != "":
+		return u.Kind
+	case u.Message != nil:
+		return KindMessage
+	case u.Callback != nil:
+		return KindCallback
+	case u.Inline != nil:
+		return KindInlineQuery
+	}
+	return KindOther
+}
+
+// handlesKind returns true if feat sees updates of kind,
+// looking through middlewares.
+func handlesKind(feat Feature, kind UpdateKind) bool {
+	kinds := defaultKinds
+	for _, f := range featureChain(feat) {
+		if handler, ok := f.(KindHandler); ok {
+			kinds = handler.Kinds()
+			break
+		}
+	}
+
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package jbot
+
+import (
+	"context"
+	"testing"
+)
+
+// kindFeature is a fake feature that sees the updates of kinds.
+type kindFeature struct {
+	fakeFeature
+	kinds []UpdateKind
+}
+
+func (f *kindFeature) Kinds() []UpdateKind {
+	return f.kinds
+}
+
+func TestDispatchFiltersKinds(t *testing.T) {
+	plain := &fakeFeature{name: "plain", trigger: true}
+	members := &kindFeature{fakeFeature{name: "members", trigger: true}, []UpdateKind{KindMembersJoined, KindBotMember}}
+	bot := &Bot{features: []Feature{plain, members}}
+
+	joined := textUpdate("")
+	joined.Kind = KindMembersJoined
+	joined.Message.NewMembers = []User{{ID: 5, FirstName: "Kalle"}}
+	edited := textUpdate("hello")
+	edited.Kind = KindEditedMessage
+	added := Update{ID: 2, Kind: KindBotMember, BotMember: &BotMember{Chat: Chat{ID: -3}, OldStatus: "left", NewStatus: "member"}}
+
+	for _, u := range []Update{textUpdate("hello"), joined, edited, added} {
+		dispatch(context.Background(), bot, u)
+	}
+
+	if len(plain.executed) != 1 || plain.executed[0].kind() != KindMessage {
+		t.Errorf("a feature without kinds saw other updates than messages: %+v", plain.executed)
+	}
+	if len(members.executed) != 2 || members.executed[0].kind() != KindMembersJoined || members.executed[1].kind() != KindBotMember {
+		t.Errorf("a feature did not see the kinds it handles: %+v", members.executed)
+	}
+}
+
+func TestUpdateKind(t *testing.T) {
+	tests := []struct {
+		u    Update
+		kind UpdateKind
+	}{
+		{textUpdate("hello"), KindMessage},
+		{Update{Callback: &Callback{}}, KindCallback},
+		{Update{Kind: KindChannelPost, Message: &Message{}}, KindChannelPost},
+		{Update{}, KindOther},
+	}
+
+	for _, test := range tests {
+		if kind := test.u.kind(); kind != test.kind {
+			t.Errorf("expected kind %v, got %v", test.kind, kind)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package jbot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// language is a feature that lets chat administrators choose
+// the language of the bot in their own chat. It owns the command
+// "/language", which shows the language, "/language fi", which
+// sets it, and "/language auto", which makes the bot use the
+// language of each user again.
+type language struct {
+	aliases []string
+}
+
+func (l *language) String() string {
+	return "language"
+}
+
+func (l *language) Init(bot *Bot) error {
+
+	if !connected(bot.database) {
+		return errors.New("no database connection")
+	}
+
+	var tableExists bool
+	err := bot.database.QueryRow("SELECT EXISTS (SELECT * FROM chat_language)").Scan(&tableExists)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	l.aliases = configuredAliases(bot.cfg.Features, "language.aliases", "/language")
+
+	bot.languages = newChatLanguages(bot.database, bot.Log(context.Background()))
+	return nil
+}
+
+// Healthy returns an error if the database connection is lost.
+func (l *language) Healthy(bot *Bot) error {
+	return databaseHealthy(bot.database)
+}
+
+func (l *language) Describe() Description {
+	examples := []string{}
+	for _, alias := range l.aliases {
+		examples = append(examples, alias, alias+" fi", alias+" auto")
+	}
+	return Description{Examples: examples}
+}
+
+func (l *language) Commands() []string {
+	return l.aliases
+}
+
+func (l *language) Triggers(u Update) bool {
+	return isCommand(u, l.aliases)
+}
+
+func (l *language) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	if u.Command == nil {
+		return false, nil
+	}
+
+	available := strings.Join(bot.catalog.languages(), ", ")
+
+	text := ""
+	if len(u.Command.Args) == 0 {
+		if chosen, ok := bot.languages.get(ctx, u.ChatID()); ok {
+			text = bot.Text(ctx, u, "language.current", chosen, available)
+		} else {
+			text = bot.Text(ctx, u, "language.auto", available)
+		}
+	} else {
+		var err error
+		text, err = l.change(ctx, bot, u, u.Command.Args[0], available)
+		if err != nil {
+			return true, err
+		}
+	}
+
+	_, err := bot.Send(ctx, Reply{ChatID: u.ChatID(), Text: text})
+	return true, err
+}
+
+// change sets the language of the chat of u to code, if the
+// sender of u is allowed to do that, and returns the reply to send.
+func (l *language) change(ctx context.Context, bot *Bot, u Update, code string, available string) (string, error) {
+
+	chosen := ""
+	if strings.ToLower(code) != "auto" {
+		var ok bool
+		if chosen, ok = bot.catalog.match(code); !ok {
+			return bot.Text(ctx, u, "language.unknown", code, available), nil
+		}
+	}
+
+	allowed, err := canChangeFeatures(ctx, bot, u.Message)
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return bot.Text(ctx, u, "language.admins"), nil
+	}
+
+	if err = bot.languages.set(ctx, u.ChatID(), chosen); err != nil {
+		return "", err
+	}
+
+	if chosen == "" {
+		return bot.Text(ctx, u, "language.reset"), nil
+	}
+	return bot.Text(ctx, u, "language.set", chosen), nil
+}
+
+// chatLanguages keeps track of the languages chosen in each chat.
+// The languages are stored in the database and cached in memory.
+type chatLanguages struct {
+	database  *sql.DB
+	logger    *Logger    // for the lookups without the logger of an update
+	languages *chatCache // languages of the chats, empty if none was chosen
+
+	mu sync.Mutex // held while a language is changed
+}
+
+func newChatLanguages(database *sql.DB, logger *Logger) *chatLanguages {
+	c := &chatLanguages{database: database, logger: logger}
+	c.languages = newChatCache(cachedChats, c.load)
+	return c
+}
+
+// get returns the language chosen in chatID. It returns
+// false if none was chosen or it can't be loaded.
+func (c *chatLanguages) get(ctx context.Context, chatID int64) (string, bool) {
+	chosen, err := c.languages.get(ctx, chatID)
+	if err != nil {
+		contextLogger(ctx, c.logger).Error("failed to load the language of the chat", "chat", chatID, "error", err)
+		return "", false
+	}
+	return chosen.(string), chosen != ""
+}
+
+// set sets the language of chatID. An empty language removes the choice.
+func (c *chatLanguages) set(ctx context.Context, chatID int64, language string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	if language == "" {
+		_, err = c.database.ExecContext(ctx, "DELETE FROM chat_language WHERE chat_id = $1", chatID)
+	} else {
+		_, err = c.database.ExecContext(ctx, "INSERT INTO chat_language (chat_id, language) VALUES ($1, $2) ON CONFLICT (chat_id) DO UPDATE SET language = $2", chatID, language)
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	c.languages.put(chatID, language)
+	return nil
+}
+
+// load reads the language chosen in chatID from the database.
+func (c *chatLanguages) load(ctx context.Context, chatID int64) (interface{}, error) {
+	var chosen string
+	err := c.database.QueryRowContext(ctx, "SELECT language FROM chat_language WHERE chat_id = $1", chatID).Scan(&chosen)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return chosen, nil
+}
@@ -0,0 +1,91 @@
+package jbot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestChatLanguages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT language FROM chat_language").WithArgs(42).WillReturnRows(sqlmock.NewRows([]string{"language"}))
+	mock.ExpectExec("^INSERT INTO chat_language").WithArgs(42, "fi").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^DELETE FROM chat_language").WithArgs(42).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	languages := newChatLanguages(db, defaultLogger)
+	ctx := context.Background()
+
+	if _, ok := languages.get(ctx, 42); ok {
+		t.Error("a chat had a language before one was chosen")
+	}
+	if err := languages.set(ctx, 42, "fi"); err != nil {
+		t.Fatal(err)
+	}
+	// the lookup comes from the cache
+	if language, ok := languages.get(ctx, 42); !ok || language != "fi" {
+		t.Errorf("expected fi, got %q", language)
+	}
+	if err := languages.set(ctx, 42, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := languages.get(ctx, 42); ok {
+		t.Error("a chat had a language after it was removed")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLanguageCommand(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	m := &recordingMessenger{admins: map[int64]bool{}}
+	bot := &Bot{
+		messenger: m,
+		cfg:       &Config{},
+		catalog:   catalog{"fi": {"language.set": "Kieleksi vaihdettiin %v"}},
+		languages: newChatLanguages(db, defaultLogger),
+	}
+	l := &language{aliases: []string{"/language"}}
+	ctx := context.Background()
+
+	mock.ExpectQuery("^SELECT language FROM chat_language").WithArgs(1000).WillReturnRows(sqlmock.NewRows([]string{"language"}))
+	mock.ExpectExec("^INSERT INTO chat_language").WithArgs(1000, "fi").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	group := commandUpdate("/language fi")
+	group.Message.Chat.Type = "group"
+	for _, u := range []Update{commandUpdate("/language sv"), group, commandUpdate("/language FI")} {
+		if _, err := l.Execute(ctx, bot, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		"Unknown language sv. Available languages: en, fi",
+		"Only chat administrators can change the language",
+		"Kieleksi vaihdettiin fi",
+	}
+	if len(m.replies) != len(expected) {
+		t.Fatalf("expected %v replies, got %+v", len(expected), m.replies)
+	}
+	for i, text := range expected {
+		if m.replies[i].Text != text {
+			t.Errorf("reply %v: expected %q, got %q", i, text, m.replies[i].Text)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,176 @@
+package jbot
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const defaultLanguage = "en"
+
+// builtinLocales holds the translations that are built into the bot.
+// locales/en.json has every text of the built-in features in English.
+//
+//go:embed locales/*.json
+var builtinLocales embed.FS
+
+// defaultMessages are the texts of the bot in the default language.
+// They are used when the catalog has no translation for a text.
+var defaultMessages = builtinCatalog()[defaultLanguage]
+
+// catalog holds the translations of the texts of the bot
+// by language code and key. A nil catalog has no translations.
+type catalog map[string]map[string]string
+
+// builtinCatalog returns the translations in builtinLocales.
+// It panics if they are broken, which the tests catch.
+func builtinCatalog() catalog {
+	files, err := fs.Glob(builtinLocales, "locales/*.json")
+	if err != nil {
+		panic(err)
+	}
+
+	c := make(catalog)
+	for _, file := range files {
+		if err = c.read(builtinLocales, file); err != nil {
+			panic(err)
+		}
+	}
+	return c
+}
+
+// loadCatalog returns the built-in translations together with the
+// translations in dir. Every file called <language code>.json in dir
+// holds a JSON object from keys to texts, which replace the built-in
+// texts of that language. If dir is empty, only the built-in
+// translations are returned.
+func loadCatalog(dir string) (catalog, error) {
+	c := builtinCatalog()
+	if dir == "" {
+		return c, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no translations found in %v", dir)
+	}
+
+	for _, file := range files {
+		if err = c.read(os.DirFS(dir), filepath.Base(file)); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// read adds the translations in file of fsys to c.
+func (c catalog) read(fsys fs.FS, file string) error {
+	content, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return err
+	}
+
+	messages := make(map[string]string)
+	if err = json.Unmarshal(content, &messages); err != nil {
+		return fmt.Errorf("failed to read translations from %v: %v", file, err)
+	}
+
+	language := strings.ToLower(strings.TrimSuffix(path.Base(file), ".json"))
+	if c[language] == nil {
+		c[language] = make(map[string]string)
+	}
+	for key, text := range messages {
+		c[language][key] = text
+	}
+	return nil
+}
+
+// languages returns the languages of c and the default language, sorted.
+func (c catalog) languages() []string {
+	languages := []string{defaultLanguage}
+	for language := range c {
+		if language != defaultLanguage {
+			languages = append(languages, language)
+		}
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// match returns the language of c that code, such as "fi" or "pt-br",
+// refers to. It returns false if c has no such language.
+func (c catalog) match(code string) (string, bool) {
+	code = strings.ToLower(strings.Replace(code, "_", "-", -1))
+	if code == "" {
+		return "", false
+	}
+	for _, language := range []string{code, strings.SplitN(code, "-", 2)[0]} {
+		if _, ok := c[language]; ok || language == defaultLanguage {
+			return language, true
+		}
+	}
+	return "", false
+}
+
+// lookup returns the translation of key in language.
+func (c catalog) lookup(language string, key string) (string, bool) {
+	text, ok := c[language][key]
+	return text, ok
+}
+
+// text returns the translation of key in language. Texts that have
+// no translation in language are looked up in the default language.
+func (c catalog) text(language string, key string) string {
+	if text, ok := c.lookup(language, key); ok {
+		return text
+	}
+	if text, ok := c.lookup(defaultLanguage, key); ok {
+		return text
+	}
+	if text, ok := defaultMessages[key]; ok {
+		return text
+	}
+	return key
+}
+
+// Language returns the language of the replies to u. It is the language
+// chosen for the chat, or the language of the user if the bot has
+// translations for it, or the language of the config.
+func (bot *Bot) Language(ctx context.Context, u Update) string {
+	if bot.languages != nil {
+		if language, ok := bot.languages.get(ctx, u.ChatID()); ok {
+			return language
+		}
+	}
+	if sender := u.Sender(); sender != nil {
+		if language, ok := bot.catalog.match(sender.LanguageCode); ok {
+			return language
+		}
+	}
+	if bot.cfg != nil {
+		if language, ok := bot.catalog.match(bot.cfg.Language); ok {
+			return language
+		}
+	}
+	return defaultLanguage
+}
+
+// Text returns the text called key in the language of u, formatted
+// with args like fmt.Sprintf. The texts are listed in the README.
+func (bot *Bot) Text(ctx context.Context, u Update, key string, args ...interface{}) string {
+	text := bot.catalog.text(bot.Language(ctx, u), key)
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
@@ -0,0 +1,135 @@
+package jbot
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "locales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := loadCatalog(dir); err == nil {
+		t.Error("a directory with no translations was accepted")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "FI.json"), []byte(`{"help.none": "Ei mitään."}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadCatalog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := c.text("fi", "help.none"); text != "Ei mitään." {
+		t.Errorf("unexpected translation %q", text)
+	}
+	if text := c.text("fi", "toggles.enabled"); text != "%v käytössä" {
+		t.Errorf("a built-in translation was lost, got %q", text)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "sv.json"), []byte(`["not", "an", "object"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCatalog(dir); err == nil {
+		t.Error("a broken translation file was accepted")
+	}
+}
+
+func TestBuiltinCatalog(t *testing.T) {
+	c, err := loadCatalog("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if languages := c.languages(); !reflect.DeepEqual(languages, []string{"en", "fi"}) {
+		t.Errorf("unexpected built-in languages %v", languages)
+	}
+	if len(defaultMessages) == 0 || defaultMessages["help.none"] != c.text("en", "help.none") {
+		t.Error("the default messages are not the built-in English texts")
+	}
+	for key := range c["fi"] {
+		if _, ok := defaultMessages[key]; !ok {
+			t.Errorf("%q is translated but has no English text", key)
+		}
+	}
+}
+
+func TestCatalogText(t *testing.T) {
+	c := catalog{
+		"fi": {"help.none": "Ei mitään."},
+		"en": {"help.header": "Features:"},
+	}
+
+	tests := []struct {
+		language string
+		key      string
+		expected string
+	}{
+		{"fi", "help.none", "Ei mitään."},
+		{"fi", "help.header", "Features:"},
+		{"fi", "toggles.which", defaultMessages["toggles.which"]},
+		{"sv", "no.such.key", "no.such.key"},
+	}
+
+	for _, test := range tests {
+		if text := c.text(test.language, test.key); text != test.expected {
+			t.Errorf("text(%q, %q) = %q, want %q", test.language, test.key, text, test.expected)
+		}
+	}
+
+	if languages := c.languages(); !reflect.DeepEqual(languages, []string{"en", "fi"}) {
+		t.Errorf("unexpected languages %v", languages)
+	}
+}
+
+func TestCatalogMatch(t *testing.T) {
+	c := catalog{"fi": {}, "pt-br": {}}
+
+	tests := []struct {
+		code     string
+		expected string
+		ok       bool
+	}{
+		{"fi", "fi", true},
+		{"FI-fi", "fi", true},
+		{"pt_BR", "pt-br", true},
+		{"en-US", "en", true},
+		{"sv", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		if language, ok := c.match(test.code); language != test.expected || ok != test.ok {
+			t.Errorf("match(%q) = %q, %v, want %q, %v", test.code, language, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestBotLanguage(t *testing.T) {
+	bot := &Bot{cfg: &Config{Language: "fi"}, catalog: catalog{"fi": {"toggles.enabled": "%v käytössä"}}}
+	ctx := context.Background()
+
+	u := textUpdate("hello")
+	if language := bot.Language(ctx, u); language != "fi" {
+		t.Errorf("the language of the config was not used, got %q", language)
+	}
+	if text := bot.Text(ctx, u, "toggles.enabled", "decide"); text != "decide käytössä" {
+		t.Errorf("unexpected text %q", text)
+	}
+
+	u.Message.Sender.LanguageCode = "en-GB"
+	if language := bot.Language(ctx, u); language != "en" {
+		t.Errorf("the language of the user was not used, got %q", language)
+	}
+
+	u.Message.Sender.LanguageCode = "sv"
+	if language := bot.Language(ctx, u); language != "fi" {
+		t.Errorf("a language with no translations was used, got %q", language)
+	}
+}
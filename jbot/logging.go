@@ -0,0 +1,306 @@
+package jbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogConfig selects the format of the log and the lines that are written.
+type LogConfig struct {
+	Format   string            `json:"format"`   // "text" (default), "logfmt" or "json"
+	Level    string            `json:"level"`    // lowest level that is written: "debug", "info" (default), "warn" or "error"
+	Features map[string]string `json:"features"` // levels of single features, e.g. {"wisdom": "debug"}
+}
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+func (level logLevel) String() string {
+	return logLevelNames[level]
+}
+
+// parseLogLevel returns the level called name. The empty name is info.
+func parseLogLevel(name string) (logLevel, error) {
+	if name == "" {
+		return levelInfo, nil
+	}
+	for level, levelName := range logLevelNames {
+		if strings.ToLower(name) == levelName {
+			return logLevel(level), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// checkLogConfig returns an error if cfg has an unknown format or level.
+func checkLogConfig(cfg LogConfig) error {
+	switch cfg.Format {
+	case "", "text", "logfmt", "json":
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	if _, err := parseLogLevel(cfg.Level); err != nil {
+		return err
+	}
+	for _, level := range cfg.Features {
+		if _, err := parseLogLevel(level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logOutput writes the lines of a Logger and the loggers made from it
+// with With. Changes to the config apply to all of them.
+type logOutput struct {
+	text *log.Logger // text lines, with the prefix and the flags of the logger
+	raw  *log.Logger // logfmt and json lines, which have their own time
+
+	mu       sync.RWMutex
+	format   string
+	level    logLevel
+	features map[string]logLevel
+}
+
+// Logger writes structured log lines. Every line has a level, a message
+// and fields, such as the update, the chat and the feature the line is
+// about. The format and the levels come from the "log" config.
+type Logger struct {
+	output  *logOutput
+	feature string        // the feature the lines are about, empty if none
+	fields  []interface{} // keys and values of every line
+}
+
+// newLogger returns a Logger that writes to out in the text format at the info level.
+func newLogger(out *log.Logger) *Logger {
+	return &Logger{output: &logOutput{
+		text:  out,
+		raw:   log.New(out.Writer(), "", 0),
+		level: levelInfo,
+	}}
+}
+
+// configure sets the format and the levels of l and the loggers
+// made from it. cfg must have passed checkLogConfig.
+func (l *Logger) configure(cfg LogConfig) {
+	o := l.output
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.format = cfg.Format
+	o.level, _ = parseLogLevel(cfg.Level)
+	o.features = make(map[string]logLevel)
+	for feature, name := range cfg.Features {
+		o.features[feature], _ = parseLogLevel(name)
+	}
+}
+
+// With returns a logger whose lines have the fields in keysAndValues
+// in addition to those of l. The levels of a feature apply to the
+// lines of a logger with the field "feature".
+func (l *Logger) With(keysAndValues ...interface{}) *Logger {
+	child := &Logger{
+		output:  l.output,
+		feature: l.feature,
+		fields:  append(append([]interface{}{}, l.fields...), keysAndValues...),
+	}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if keysAndValues[i] == "feature" {
+			child.feature = fmt.Sprint(keysAndValues[i+1])
+		}
+	}
+	return child
+}
+
+// Debug writes a line for debugging, such as every update a feature executes.
+func (l *Logger) Debug(msg string, keysAndValues ...interface{}) {
+	l.write(levelDebug, msg, keysAndValues)
+}
+
+// Info writes a line about normal operation, such as a reloaded config.
+func (l *Logger) Info(msg string, keysAndValues ...interface{}) {
+	l.write(levelInfo, msg, keysAndValues)
+}
+
+// Warn writes a line about a problem the bot recovers from, such as a dropped update.
+func (l *Logger) Warn(msg string, keysAndValues ...interface{}) {
+	l.write(levelWarn, msg, keysAndValues)
+}
+
+// Error writes a line about a failure, such as an error returned by a feature.
+func (l *Logger) Error(msg string, keysAndValues ...interface{}) {
+	l.write(levelError, msg, keysAndValues)
+}
+
+// enabled returns true if l writes lines of level.
+func (l *Logger) enabled(level logLevel) bool {
+	o := l.output
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if featureLevel, ok := o.features[l.feature]; ok && l.feature != "" {
+		return level >= featureLevel
+	}
+	return level >= o.level
+}
+
+func (l *Logger) write(level logLevel, msg string, keysAndValues []interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+
+	fields := append(append([]interface{}{}, l.fields...), keysAndValues...)
+	if len(fields)%2 == 1 {
+		fields = append(fields, "")
+	}
+
+	o := l.output
+	o.mu.RLock()
+	format := o.format
+	o.mu.RUnlock()
+
+	switch format {
+	case "json":
+		o.raw.Print(formatJSONLine(time.Now(), level, msg, fields))
+	case "logfmt":
+		o.raw.Print(formatLogfmtLine(time.Now(), level, msg, fields))
+	default:
+		o.text.Print(formatTextLine(level, msg, fields))
+	}
+}
+
+// formatTextLine formats a line for people, e.g. "WARN dropping update update=7 chat=1000".
+func formatTextLine(level logLevel, msg string, fields []interface{}) string {
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(fields); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", fields[i], logValue(fields[i+1]))
+	}
+	return b.String()
+}
+
+// formatLogfmtLine formats a line as logfmt, e.g.
+// time=2026-06-20T04:00:00Z level=warn msg="dropping update" update=7 chat=1000
+func formatLogfmtLine(t time.Time, level logLevel, msg string, fields []interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "time=%v level=%v msg=%v", t.Format(time.RFC3339Nano), level, logfmtValue(msg))
+	for i := 0; i < len(fields); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", fields[i], logfmtValue(fmt.Sprint(logValue(fields[i+1]))))
+	}
+	return b.String()
+}
+
+// logfmtValue quotes s if it has spaces, quotes or equal signs.
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// formatJSONLine formats a line as a JSON object with the keys time,
+// level, msg and the keys of the fields, in that order.
+func formatJSONLine(t time.Time, level logLevel, msg string, fields []interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `{"time":%v,"level":%v,"msg":%v`, jsonValue(t.Format(time.RFC3339Nano)), jsonValue(level.String()), jsonValue(msg))
+	for i := 0; i < len(fields); i += 2 {
+		fmt.Fprintf(&b, ",%v:%v", jsonValue(fmt.Sprint(fields[i])), jsonValue(logValue(fields[i+1])))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func jsonValue(v interface{}) string {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return string(encoded)
+}
+
+// logValue returns v in the form it is logged in. Errors, durations
+// and other values that describe themselves are logged as text.
+func logValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+// logWriter writes every line it gets as an info line of a Logger.
+type logWriter struct {
+	logger *Logger
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.logger.Info(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// std returns a standard logger that writes its lines as info lines of l.
+func (l *Logger) std() *log.Logger {
+	return log.New(logWriter{l}, "", 0)
+}
+
+type loggerKey struct{}
+
+// withLogger returns a copy of ctx that carries l.
+func withLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// updateLogger returns a logger whose lines carry the update, the chat
+// and the user of u.
+func updateLogger(l *Logger, u Update) *Logger {
+	fields := []interface{}{"update", u.ID, "kind", u.kind()}
+	if chatID := u.ChatID(); chatID != 0 {
+		fields = append(fields, "chat", chatID)
+	}
+	if sender := u.Sender(); sender != nil {
+		fields = append(fields, "user", sender.ID)
+	}
+	return l.With(fields...)
+}
+
+// Log returns the logger for ctx. While the bot handles an update,
+// its lines carry the update, the chat, the user and the feature,
+// and while a job runs, the job and the feature.
+func (bot *Bot) Log(ctx context.Context) *Logger {
+	if bot.logger == nil {
+		return contextLogger(ctx, defaultLogger)
+	}
+	return contextLogger(ctx, bot.logger)
+}
+
+// contextLogger returns the logger carried by ctx, or l if it has none.
+// Parts of the bot that log for a feature without the Bot use it, so
+// that their lines carry the fields of the update or job.
+func contextLogger(ctx context.Context, l *Logger) *Logger {
+	if ctxLogger, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return ctxLogger
+	}
+	return l
+}
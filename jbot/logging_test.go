@@ -0,0 +1,149 @@
+package jbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bufferLogger returns a logger configured with cfg that writes to the returned buffer.
+func bufferLogger(cfg LogConfig) (*Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	l := newLogger(log.New(&buf, "", 0))
+	l.configure(cfg)
+	return l, &buf
+}
+
+// logLines returns the JSON lines in buf as maps.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		lines = append(lines, fields)
+	}
+	return lines
+}
+
+func TestLoggerFormats(t *testing.T) {
+	l, buf := bufferLogger(LogConfig{})
+	l.With("chat", 1000).Warn("dropping update", "update", 7)
+	if line := buf.String(); line != "WARN dropping update chat=1000 update=7\n" {
+		t.Errorf("unexpected text line %q", line)
+	}
+
+	l, buf = bufferLogger(LogConfig{Format: "logfmt"})
+	l.Error("feature failed", "feature", "decide", "error", errors.New("no options"), "latency", 1500*time.Millisecond)
+	line := buf.String()
+	if !strings.HasPrefix(line, "time=") || !strings.HasSuffix(line, ` level=error msg="feature failed" feature=decide error="no options" latency=1.5s`+"\n") {
+		t.Errorf("unexpected logfmt line %q", line)
+	}
+
+	l, buf = bufferLogger(LogConfig{Format: "json"})
+	l.Info("update handled", "update", 7, "text", `say "hi"`)
+	lines := logLines(t, buf)
+	if len(lines) != 1 || lines[0]["level"] != "info" || lines[0]["msg"] != "update handled" ||
+		lines[0]["update"] != 7.0 || lines[0]["text"] != `say "hi"` || lines[0]["time"] == nil {
+		t.Errorf("unexpected json line %v", lines)
+	}
+}
+
+func TestLoggerLevels(t *testing.T) {
+	l, buf := bufferLogger(LogConfig{Format: "json", Level: "warn", Features: map[string]string{"wisdom": "debug", "decide": "error"}})
+
+	l.Info("not written")
+	l.Warn("written")
+	l.With("feature", "wisdom").Debug("written for wisdom")
+	l.With("feature", "decide").Warn("not written for decide")
+	l.With("feature", "decide").Error("written for decide")
+
+	var messages []string
+	for _, line := range logLines(t, buf) {
+		messages = append(messages, line["msg"].(string))
+	}
+	if strings.Join(messages, ", ") != "written, written for wisdom, written for decide" {
+		t.Errorf("unexpected lines %v", messages)
+	}
+
+	// a reloaded config changes the loggers made before it
+	wisdom := l.With("feature", "wisdom")
+	l.configure(LogConfig{Format: "json"})
+	buf.Reset()
+	wisdom.Debug("not written after reload")
+	if buf.Len() != 0 {
+		t.Errorf("unexpected lines after reload: %q", buf.String())
+	}
+}
+
+func TestCheckLogConfig(t *testing.T) {
+	for _, cfg := range []LogConfig{
+		{Format: "xml"},
+		{Level: "loud"},
+		{Features: map[string]string{"decide": "verbose"}},
+	} {
+		if err := checkLogConfig(cfg); err == nil {
+			t.Errorf("log config %+v was accepted", cfg)
+		}
+	}
+	if err := checkLogConfig(LogConfig{Format: "logfmt", Level: "DEBUG", Features: map[string]string{"decide": "warn"}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStandardLoggerWritesInfoLines(t *testing.T) {
+	l, buf := bufferLogger(LogConfig{Format: "json"})
+	bot := &Bot{logger: l.With("feature", "wisdom")}
+	bot.Logger().Printf("loaded %v wisdoms", 3)
+
+	lines := logLines(t, buf)
+	if len(lines) != 1 || lines[0]["msg"] != "loaded 3 wisdoms" || lines[0]["feature"] != "wisdom" || lines[0]["level"] != "info" {
+		t.Errorf("unexpected lines %v", lines)
+	}
+}
+
+// loggingTestFeature logs a line with the logger of the bot.
+type loggingTestFeature struct {
+	fakeFeature
+}
+
+func (f *loggingTestFeature) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	bot.Log(ctx).Info("hello from the feature")
+	return f.fakeFeature.Execute(ctx, bot, u)
+}
+
+func TestDispatchLogsUpdateContext(t *testing.T) {
+	l, buf := bufferLogger(LogConfig{Format: "json", Level: "debug"})
+	feat := &loggingTestFeature{fakeFeature{name: "greeter", trigger: true, handle: true}}
+	bot := &Bot{features: []Feature{feat}, logger: l}
+
+	dispatch(context.Background(), bot, textUpdate("hello"))
+
+	lines := logLines(t, buf)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %v", lines)
+	}
+	for _, line := range lines {
+		if line["update"] != 1.0 || line["chat"] != 1000.0 || line["user"] != 100.0 {
+			t.Errorf("line without the update, chat and user: %v", line)
+		}
+	}
+	if lines[0]["msg"] != "hello from the feature" || lines[0]["feature"] != "greeter" {
+		t.Errorf("unexpected line of the feature %v", lines[0])
+	}
+	if lines[1]["msg"] != "feature executed" || lines[1]["feature"] != "greeter" || lines[1]["latency"] == nil || lines[1]["handled"] != true {
+		t.Errorf("unexpected line about the feature %v", lines[1])
+	}
+	if lines[2]["msg"] != "update handled" || lines[2]["latency"] == nil {
+		t.Errorf("unexpected line about the update %v", lines[2])
+	}
+}
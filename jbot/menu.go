@@ -0,0 +1,235 @@
+package jbot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	maxMenuCommands          = 100
+	maxMenuDescriptionLength = 256
+	menuSyncTimeout          = 30 * time.Second
+)
+
+// menuCommandName matches the commands that telegram accepts in the menu,
+// without the slash.
+var menuCommandName = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// menuScopes are the scopes of command lists that telegram knows.
+// The value is true for the scopes that need a chat ID.
+var menuScopes = map[string]bool{
+	"default":                 false,
+	"all_private_chats":       false,
+	"all_group_chats":         false,
+	"all_chat_administrators": false,
+	"chat":                    true,
+	"chat_administrators":     true,
+	"chat_member":             true,
+}
+
+// CommandMenuConfig is a list of commands shown in the command menu
+// of telegram for one scope and language.
+type CommandMenuConfig struct {
+	Scope        string            `json:"scope"`        // one of the scopes of telegram, e.g. "all_group_chats", default "default"
+	ChatID       int64             `json:"chatid"`       // chat of the "chat", "chat_administrators" and "chat_member" scopes
+	UserID       int64             `json:"userid"`       // user of the "chat_member" scope
+	Language     string            `json:"language"`     // two-letter language code, empty for every language
+	Commands     []string          `json:"commands"`     // commands in the list, every command by default
+	Descriptions map[string]string `json:"descriptions"` // descriptions that replace the ones of the features
+}
+
+// menuCommand is a command in the command menu.
+type menuCommand struct {
+	Command     string `json:"command"` // without the slash
+	Description string `json:"description"`
+}
+
+// menuScope is the scope of a command list as telegram expects it.
+type menuScope struct {
+	Type   string `json:"type"`
+	ChatID int64  `json:"chat_id,omitempty"`
+	UserID int64  `json:"user_id,omitempty"`
+}
+
+// menuList identifies a command list of telegram.
+type menuList struct {
+	scope    menuScope
+	language string
+}
+
+// commandMenu is implemented by messengers that have a command menu.
+type commandMenu interface {
+	// setCommands replaces the commands of scope and language.
+	// An empty list of commands removes the list.
+	setCommands(ctx context.Context, scope menuScope, language string, commands []menuCommand) error
+}
+
+// menuCommander is implemented by features whose slash commands
+// have descriptions of their own, like the pings of pingpong.
+type menuCommander interface {
+	menuCommands() []menuCommand
+}
+
+// checkCommandMenu returns an error if a list of cfg has an unknown scope.
+func checkCommandMenu(lists []CommandMenuConfig) error {
+	for _, list := range lists {
+		needsChat, ok := menuScopes[list.scope().Type]
+		if !ok {
+			return fmt.Errorf("unknown command menu scope %q", list.Scope)
+		}
+		if needsChat && list.ChatID == 0 {
+			return fmt.Errorf("command menu scope %q needs a chatid", list.Scope)
+		}
+	}
+	return nil
+}
+
+func (list CommandMenuConfig) scope() menuScope {
+	scope := menuScope{Type: list.Scope, ChatID: list.ChatID, UserID: list.UserID}
+	if scope.Type == "" {
+		scope.Type = "default"
+	}
+	return scope
+}
+
+// commands returns the commands of all that belong to list,
+// with the descriptions of list.
+func (list CommandMenuConfig) commands(all []menuCommand) []menuCommand {
+	wanted := make(map[string]bool)
+	for _, name := range list.Commands {
+		wanted[strings.TrimPrefix(strings.ToLower(name), "/")] = true
+	}
+
+	commands := []menuCommand{}
+	for _, c := range all {
+		if len(wanted) > 0 && !wanted[c.Command] {
+			continue
+		}
+		if description, ok := list.Descriptions["/"+c.Command]; ok {
+			c.Description = description
+		} else if description, ok := list.Descriptions[c.Command]; ok {
+			c.Description = description
+		}
+		commands = append(commands, menuCommandOf(c.Command, c.Description))
+	}
+	if len(commands) > maxMenuCommands {
+		commands = commands[:maxMenuCommands]
+	}
+	return commands
+}
+
+// menuCommandOf returns a menu command with a description that telegram accepts.
+func menuCommandOf(name string, description string) menuCommand {
+	description = strings.TrimSpace(description)
+	if description == "" {
+		description = name
+	}
+	if runes := []rune(description); len(runes) > maxMenuDescriptionLength {
+		description = string(runes[:maxMenuDescriptionLength-1]) + "…"
+	}
+	return menuCommand{Command: name, Description: description}
+}
+
+// menuLanguage returns the language of the descriptions of list: the
+// language of list if the catalog has it, or else the language of the config.
+func menuLanguage(bot *Bot, list CommandMenuConfig) string {
+	codes := []string{list.Language}
+	if bot.cfg != nil {
+		codes = append(codes, bot.cfg.Language)
+	}
+	for _, code := range codes {
+		if language, ok := bot.catalog.match(code); ok {
+			return language
+		}
+	}
+	return defaultLanguage
+}
+
+// menuCommands returns the slash commands of the running features of bot
+// in priority order, described in language. Commands that telegram does
+// not accept are left out.
+func menuCommands(bot *Bot, language string) []menuCommand {
+	commands := []menuCommand{}
+	seen := make(map[string]bool)
+	add := func(c menuCommand) {
+		if !menuCommandName.MatchString(c.Command) || seen[c.Command] {
+			return
+		}
+		seen[c.Command] = true
+		commands = append(commands, menuCommandOf(c.Command, c.Description))
+	}
+
+	for _, feat := range bot.features {
+		description, _ := describe(feat)
+		description = translateDescription(bot, language, feat.String(), description)
+		// the commands with their own descriptions come first
+		for _, f := range featureChain(feat) {
+			if m, ok := f.(menuCommander); ok {
+				for _, c := range m.menuCommands() {
+					add(c)
+				}
+			}
+		}
+		if commander, ok := commanderOf(feat); ok {
+			for _, name := range commander.Commands() {
+				name = strings.ToLower(name)
+				if strings.HasPrefix(name, "/") {
+					add(menuCommand{strings.TrimPrefix(name, "/"), description.Summary})
+				}
+			}
+		}
+	}
+
+	return commands
+}
+
+// syncCommands sets the command menus of the config of bot to the commands
+// of its features, if bot is still the current one when it is its turn.
+// The lists that an earlier sync set and the config of bot no longer has
+// are removed.
+func (r *Runner) syncCommands(bot *Bot) {
+	if r.menu == nil {
+		return
+	}
+
+	r.menuMu.Lock()
+	defer r.menuMu.Unlock()
+
+	if r.current.Load().(*Bot) != bot {
+		return // the newer bot syncs its own commands
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), menuSyncTimeout)
+	defer cancel()
+
+	lists := bot.cfg.CommandMenu
+	if len(lists) == 0 {
+		lists = []CommandMenuConfig{{}}
+	}
+
+	synced := make(map[menuList]bool)
+	for _, list := range lists {
+		key := menuList{list.scope(), list.Language}
+		synced[key] = true
+
+		all := menuCommands(bot, menuLanguage(bot, list))
+		if err := r.menu.setCommands(ctx, key.scope, key.language, list.commands(all)); err != nil {
+			r.logger.Error("failed to set the command menu", "scope", key.scope.Type, "language", key.language, "error", err)
+		}
+	}
+
+	for key := range r.menus {
+		if synced[key] {
+			continue
+		}
+		if err := r.menu.setCommands(ctx, key.scope, key.language, []menuCommand{}); err != nil {
+			r.logger.Error("failed to remove the command menu", "scope", key.scope.Type, "language", key.language, "error", err)
+			synced[key] = true // removed again by the next sync
+		}
+	}
+	r.menus = synced
+	r.logger.Info("command menu updated", "lists", len(lists))
+}
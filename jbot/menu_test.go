@@ -0,0 +1,153 @@
+package jbot
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type menuCall struct {
+	scope    menuScope
+	language string
+	commands []menuCommand
+}
+
+// recordingMenu is a commandMenu that records the lists it is given.
+type recordingMenu struct {
+	calls []menuCall
+	err   error
+}
+
+func (m *recordingMenu) setCommands(ctx context.Context, scope menuScope, language string, commands []menuCommand) error {
+	m.calls = append(m.calls, menuCall{scope, language, commands})
+	return m.err
+}
+
+func TestMenuCommands(t *testing.T) {
+	d := &decide{triggerWords: []string{"/decide", "!choose", "/Päätä"}}
+	h := &help{aliases: []string{"/help", "/decide"}}
+	p := &pingpong{features: []pingpongFeature{
+		{Pings: []string{"/ping", "pong"}, IsPrefixCommand: true, Description: "Pong!"},
+		{Pings: []string{"/ignored"}},
+	}}
+	bot := &Bot{features: []Feature{d, h, p, &fakeFeature{name: "plain"}}}
+
+	got := menuCommands(bot, defaultLanguage)
+	want := []menuCommand{
+		{"decide", defaultMessages["decide.summary"]},
+		{"help", defaultMessages["help.summary"]},
+		{"ping", "Pong!"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("menuCommands() = %v, want %v", got, want)
+	}
+}
+
+func TestCommandMenuListCommands(t *testing.T) {
+	all := []menuCommand{{"decide", "Decides."}, {"help", "Helps."}, {"ping", ""}}
+
+	list := CommandMenuConfig{
+		Commands:     []string{"/Help", "ping"},
+		Descriptions: map[string]string{"/help": "Apua.", "ping": strings.Repeat("x", 300)},
+	}
+	got := list.commands(all)
+	if len(got) != 2 || got[0] != (menuCommand{"help", "Apua."}) || got[1].Command != "ping" {
+		t.Fatalf("unexpected commands %v", got)
+	}
+	if n := len([]rune(got[1].Description)); n != maxMenuDescriptionLength {
+		t.Errorf("description was not truncated to %v characters, got %v", maxMenuDescriptionLength, n)
+	}
+
+	if got := (CommandMenuConfig{}).commands(all); len(got) != 3 || got[2] != (menuCommand{"ping", "ping"}) {
+		t.Errorf("a list with no commands did not get every command with descriptions: %v", got)
+	}
+}
+
+func TestCheckCommandMenu(t *testing.T) {
+	tests := []struct {
+		lists []CommandMenuConfig
+		ok    bool
+	}{
+		{nil, true},
+		{[]CommandMenuConfig{{}, {Scope: "all_group_chats", Language: "fi"}}, true},
+		{[]CommandMenuConfig{{Scope: "chat", ChatID: -100}}, true},
+		{[]CommandMenuConfig{{Scope: "chat"}}, false},
+		{[]CommandMenuConfig{{Scope: "everyone"}}, false},
+	}
+
+	for _, test := range tests {
+		if err := checkCommandMenu(test.lists); (err == nil) != test.ok {
+			t.Errorf("checkCommandMenu(%+v) = %v", test.lists, err)
+		}
+	}
+}
+
+func TestSyncCommands(t *testing.T) {
+	menu := &recordingMenu{err: errors.New("too many requests")}
+	r := &Runner{menu: menu, logger: defaultLogger}
+
+	cfg := &Config{CommandMenu: []CommandMenuConfig{
+		{Scope: "all_private_chats"},
+		{Scope: "chat", ChatID: 42, Language: "fi", Commands: []string{"/help"}},
+	}}
+	bot := &Bot{cfg: cfg, features: []Feature{&decide{triggerWords: []string{"/decide"}}, &help{aliases: []string{"/help"}}}}
+	r.current.Store(bot)
+
+	r.syncCommands(bot)
+	if len(menu.calls) != 2 {
+		t.Fatalf("expected 2 lists to be set even if setting one fails, got %v", len(menu.calls))
+	}
+	if call := menu.calls[0]; call.scope.Type != "all_private_chats" || len(call.commands) != 2 {
+		t.Errorf("unexpected first list %+v", call)
+	}
+	if call := menu.calls[1]; call.scope != (menuScope{"chat", 42, 0}) || call.language != "fi" || len(call.commands) != 1 {
+		t.Errorf("unexpected second list %+v", call)
+	}
+
+	r.current.Store(&Bot{cfg: &Config{}})
+	r.syncCommands(bot)
+	if len(menu.calls) != 2 {
+		t.Errorf("a bot that is no longer current set the command menu")
+	}
+}
+
+func TestSyncCommandsRemovesOldLists(t *testing.T) {
+	menu := &recordingMenu{}
+	r := &Runner{menu: menu, logger: defaultLogger}
+	features := []Feature{&decide{triggerWords: []string{"/decide"}}}
+	messages := catalog{"fi": {"decide.summary": "Valitsee puolestasi."}}
+
+	bot := &Bot{cfg: &Config{CommandMenu: []CommandMenuConfig{
+		{Scope: "all_private_chats"},
+		{Scope: "all_group_chats", Language: "fi"},
+	}}, features: features, catalog: messages}
+	r.current.Store(bot)
+	r.syncCommands(bot)
+	if len(menu.calls) != 2 {
+		t.Fatalf("expected 2 lists, got %+v", menu.calls)
+	}
+	if call := menu.calls[1]; call.commands[0].Description != "Valitsee puolestasi." {
+		t.Errorf("the description was not translated to the language of the list: %+v", call)
+	}
+
+	// the group list is removed from the config on reload
+	menu.calls = nil
+	bot = &Bot{cfg: &Config{CommandMenu: []CommandMenuConfig{{Scope: "all_private_chats"}}}, features: features, catalog: messages}
+	r.current.Store(bot)
+	r.syncCommands(bot)
+	if len(menu.calls) != 2 {
+		t.Fatalf("expected the private list to be set and the group list removed, got %+v", menu.calls)
+	}
+	if call := menu.calls[1]; call.scope.Type != "all_group_chats" || call.language != "fi" || len(call.commands) != 0 {
+		t.Errorf("the removed list was not cleared: %+v", call)
+	}
+
+	// a cleared list is not cleared again
+	menu.calls = nil
+	r.syncCommands(bot)
+	if len(menu.calls) != 1 {
+		t.Errorf("expected only the private list, got %+v", menu.calls)
+	}
+}
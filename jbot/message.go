@@ -0,0 +1,147 @@
+package jbot
+
+import (
+	"context"
+	"time"
+)
+
+// Update is something that happened in a chat.
+// Features react to updates without knowing where they came from.
+type Update struct {
+	ID        int
+	Kind      UpdateKind // what happened, see the Kind constants
+	Message   *Message   // new or edited message or channel post, if any
+	Callback  *Callback  // pressed inline keyboard button, if any
+	BotMember *BotMember // change in the membership of the bot, if any
+	Inline    *Inline    // inline query, if any
+	Command   *Command   // command that the message starts with, if a running feature owns it
+
+	routed bool // true while the command is executed by the feature that owns it
+}
+
+// Message is a message sent to a chat.
+type Message struct {
+	ID     int
+	Text   string
+	Sender *User // nil for messages that were not sent by a user
+	Chat   Chat
+
+	NewMembers []User // users that joined the chat, if the message tells that
+	LeftMember *User  // user that left the chat, if the message tells that
+}
+
+// User is the sender of a message or callback.
+type User struct {
+	ID           int64
+	UserName     string
+	FirstName    string
+	LanguageCode string
+}
+
+// Chat is a conversation between the bot and one or more users.
+type Chat struct {
+	ID    int64
+	Type  string // "private", "group", "supergroup" or "channel"
+	Title string
+}
+
+// BotMember is sent when the status of the bot in a chat changes,
+// for example when the bot is added to a group or removed from it.
+type BotMember struct {
+	Chat      Chat
+	By        *User  // user that changed the status, if known
+	OldStatus string // "member", "administrator", "left", "kicked" etc.
+	NewStatus string
+}
+
+// Inline is sent when a user types "@bot query" in any chat.
+// It is answered with results the user can pick from.
+type Inline struct {
+	ID     string
+	Query  string // the text after the username of the bot
+	Sender *User
+}
+
+// InlineAnswer is the answer to an inline query.
+type InlineAnswer struct {
+	Results   []InlineResult
+	CacheTime time.Duration // how long the answer may be reused for the same query, 0 for not at all
+}
+
+// InlineResult is one of the results of an inline query. When the user
+// picks it, Text is sent to the chat in the name of the user.
+type InlineResult struct {
+	Title       string
+	Description string // shown below the title, optional
+	Text        string
+}
+
+// Callback is sent when a user presses a button of an inline keyboard.
+type Callback struct {
+	ID      string
+	Data    string // data of the button that was pressed
+	Sender  *User
+	Message *Message // message the keyboard was attached to, if available
+}
+
+// ChatID returns the ID of the chat u happened in. Callbacks without
+// a message and inline queries are identified by the user that sent them.
+func (u Update) ChatID() int64 {
+	switch {
+	case u.Message != nil:
+		return u.Message.Chat.ID
+	case u.Callback != nil && u.Callback.Message != nil:
+		return u.Callback.Message.Chat.ID
+	case u.Callback != nil && u.Callback.Sender != nil:
+		return u.Callback.Sender.ID
+	case u.BotMember != nil:
+		return u.BotMember.Chat.ID
+	case u.Inline != nil && u.Inline.Sender != nil:
+		return u.Inline.Sender.ID
+	}
+	return 0
+}
+
+// Sender returns the user that sent u, or nil if it is not known.
+func (u Update) Sender() *User {
+	switch {
+	case u.Message != nil:
+		return u.Message.Sender
+	case u.Callback != nil:
+		return u.Callback.Sender
+	case u.BotMember != nil:
+		return u.BotMember.By
+	case u.Inline != nil:
+		return u.Inline.Sender
+	}
+	return nil
+}
+
+// Reply is a message the bot sends to a chat.
+type Reply struct {
+	ChatID           int64
+	Text             string
+	ReplyToMessageID int        // if set, the reply quotes this message
+	EditMessageID    int        // if set, this message of the bot is edited instead of sending a new one
+	Keyboard         [][]Button // rows of an inline keyboard shown with the reply
+}
+
+// Button is a button of an inline keyboard. Pressing it
+// sends a callback with the data of the button.
+type Button struct {
+	Text string
+	Data string
+}
+
+// messenger delivers replies to chats.
+type messenger interface {
+	// send sends r and returns the ID of the sent message.
+	send(ctx context.Context, r Reply) (int, error)
+	// answerCallback tells the user that pressed a button that their
+	// callback was received. text is shown to the user if not empty.
+	answerCallback(ctx context.Context, callbackID string, text string) error
+	// isAdmin returns true if userID is an administrator of chatID.
+	isAdmin(ctx context.Context, chatID int64, userID int64) (bool, error)
+	// answerInline answers the inline query queryID.
+	answerInline(ctx context.Context, queryID string, answer InlineAnswer) error
+}
@@ -0,0 +1,186 @@
+package jbot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a feature to add behaviour around its Triggers and
+// Execute methods, for example to throttle a feature or to check who
+// may use it. The returned feature usually embeds the wrapped one, so
+// that the methods it does not override are passed through, and
+// implements Wrapper, so that the bot finds the commands, jobs and
+// other interfaces of the wrapped feature. Triggers of the middlewares
+// is called for commands too, before the command is executed.
+// Middlewares that log write to the logger of the update.
+type Middleware func(feat Feature) Feature
+
+// Wrapper is implemented by the features of middlewares.
+// Unwrap returns the wrapped feature.
+type Wrapper interface {
+	Unwrap() Feature
+}
+
+var (
+	middlewareMu sync.Mutex
+	// middlewares are the middlewares that can be enabled in the config by name.
+	middlewares = map[string]Middleware{
+		"logging": withLogging,
+		"recover": withRecover,
+		"timing":  withTiming,
+	}
+)
+
+// RegisterMiddleware makes a middleware available in the config under
+// name. Like Register, it is meant to be called from the init function
+// of the package that provides the middleware. RegisterMiddleware
+// panics if a middleware with the same name is already registered.
+func RegisterMiddleware(name string, m Middleware) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+
+	if m == nil {
+		panic("jbot: RegisterMiddleware called with a nil middleware")
+	}
+	if _, ok := middlewares[name]; ok {
+		panic("jbot: RegisterMiddleware called twice for middleware " + name)
+	}
+	middlewares[name] = m
+}
+
+// middlewareCalled returns the middleware registered under name.
+func middlewareCalled(name string) (Middleware, bool) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+
+	m, ok := middlewares[name]
+	return m, ok
+}
+
+// allFeaturesKey is the key of the middleware config that applies to every feature.
+const allFeaturesKey = "*"
+
+// applyMiddleware wraps feat with the middlewares configured for it.
+// The middlewares for all features come first, and the first middleware
+// in a list is the outermost one. On error, feat is returned unwrapped.
+func applyMiddleware(feat Feature, cfg map[string][]string) (Feature, error) {
+	names := append([]string{}, cfg[allFeaturesKey]...)
+	names = append(names, cfg[feat.String()]...)
+	if len(names) == 0 {
+		return feat, nil
+	}
+
+	// wrap from the innermost middleware outwards
+	var wrapped Feature = routedFeature{feat}
+	for i := len(names) - 1; i >= 0; i-- {
+		wrap, ok := middlewareCalled(names[i])
+		if !ok {
+			return feat, fmt.Errorf("unknown middleware %q", names[i])
+		}
+		wrapped = wrap(wrapped)
+	}
+	return wrapped, nil
+}
+
+// checkMiddleware returns an error if cfg names a middleware that doesn't exist.
+func checkMiddleware(cfg map[string][]string) error {
+	for feature, names := range cfg {
+		for _, name := range names {
+			if _, ok := middlewareCalled(name); !ok {
+				return fmt.Errorf("unknown middleware %q for %v", name, feature)
+			}
+		}
+	}
+	return nil
+}
+
+// featureChain returns feat and the features wrapped
+// inside it, from the outermost to the innermost.
+func featureChain(feat Feature) []Feature {
+	chain := []Feature{feat}
+	for {
+		w, ok := feat.(Wrapper)
+		if !ok {
+			return chain
+		}
+		feat = w.Unwrap()
+		chain = append(chain, feat)
+	}
+}
+
+// routedFeature is the innermost feature of a middleware chain. It
+// triggers for the commands routed to its feature, which are executed
+// without asking the feature, so that only the middlewares decide.
+type routedFeature struct {
+	Feature
+}
+
+func (f routedFeature) Unwrap() Feature {
+	return f.Feature
+}
+
+func (f routedFeature) Triggers(u Update) bool {
+	return u.routed || f.Feature.Triggers(u)
+}
+
+// loggingFeature logs every update its feature executes.
+type loggingFeature struct {
+	Feature
+}
+
+func withLogging(feat Feature) Feature {
+	return loggingFeature{feat}
+}
+
+func (f loggingFeature) Unwrap() Feature {
+	return f.Feature
+}
+
+func (f loggingFeature) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	logger := bot.Log(ctx)
+	logger.Info("executing update")
+	handled, err := f.Feature.Execute(ctx, bot, u)
+	logger.Info("executed update", "handled", handled, "error", err)
+	return handled, err
+}
+
+// timingFeature logs how long its feature takes to execute an update.
+type timingFeature struct {
+	Feature
+}
+
+func withTiming(feat Feature) Feature {
+	return timingFeature{feat}
+}
+
+func (f timingFeature) Unwrap() Feature {
+	return f.Feature
+}
+
+func (f timingFeature) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	start := time.Now()
+	handled, err := f.Feature.Execute(ctx, bot, u)
+	bot.Log(ctx).Info("executed update", "latency", time.Since(start))
+	return handled, err
+}
+
+// recoverFeature turns the panics of its feature into errors, so that
+// the middlewares around it see them. The bot recovers from panics in
+// features also without it.
+type recoverFeature struct {
+	Feature
+}
+
+func withRecover(feat Feature) Feature {
+	return recoverFeature{feat}
+}
+
+func (f recoverFeature) Unwrap() Feature {
+	return f.Feature
+}
+
+func (f recoverFeature) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	return callExecute(ctx, bot, f.Feature, u)
+}
@@ -0,0 +1,110 @@
+package jbot
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func init() {
+	RegisterMiddleware("onlyuser100", func(feat Feature) Feature { return onlyUser100{feat} })
+}
+
+// onlyUser100 is a middleware that lets only the user 100 use its feature.
+type onlyUser100 struct {
+	Feature
+}
+
+func (f onlyUser100) Unwrap() Feature {
+	return f.Feature
+}
+
+func (f onlyUser100) Triggers(u Update) bool {
+	return u.Message != nil && u.Message.Sender.ID == 100 && f.Feature.Triggers(u)
+}
+
+func TestApplyMiddlewareOrder(t *testing.T) {
+	cfg := map[string][]string{
+		"*":      {"timing"},
+		"decide": {"logging", "timing"},
+	}
+
+	feat, err := applyMiddleware(&fakeFeature{name: "decide"}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outer, ok := feat.(timingFeature)
+	if !ok {
+		t.Fatalf("outermost middleware was %T, not timing", feat)
+	}
+	middle, ok := outer.Feature.(loggingFeature)
+	if !ok {
+		t.Fatalf("second middleware was %T, not logging", outer.Feature)
+	}
+	if _, ok := middle.Feature.(timingFeature); !ok {
+		t.Fatalf("innermost middleware was %T, not timing", middle.Feature)
+	}
+	if feat.String() != "decide" {
+		t.Errorf("wrapped feature is called %v instead of decide", feat.String())
+	}
+}
+
+func TestCheckMiddleware(t *testing.T) {
+	if err := checkMiddleware(map[string][]string{"*": {"timing"}, "wisdom": {"logging"}}); err != nil {
+		t.Error(err)
+	}
+	if err := checkMiddleware(map[string][]string{"*": {"recover"}, "decide": {"onlyuser100"}}); err != nil {
+		t.Error(err)
+	}
+	if err := checkMiddleware(map[string][]string{"*": {"nonexistent"}}); err == nil {
+		t.Error("an unknown middleware was accepted")
+	}
+}
+
+func TestApplyMiddlewareUnknown(t *testing.T) {
+	original := &fakeFeature{name: "decide"}
+	feat, err := applyMiddleware(original, map[string][]string{"decide": {"nonexistent"}})
+	if err == nil {
+		t.Error("unknown middleware did not produce an error")
+	}
+	if feat != original {
+		t.Error("feature was not returned unwrapped on error")
+	}
+}
+
+func TestRegisteredMiddlewareTriggersCommands(t *testing.T) {
+	// echo does not trigger by itself, but its command is routed to it
+	echo := &inlineFeature{fakeFeature: fakeFeature{name: "echo", handle: true}, commands: []string{"/echo"}}
+	feat, err := applyMiddleware(echo, map[string][]string{"echo": {"onlyuser100"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	features := []Feature{feat}
+	bot := &Bot{messenger: &recordingMessenger{}, features: features, commands: newCommandTable(features)}
+
+	dispatch(context.Background(), bot, textUpdate("/echo hi"))
+	if len(echo.executed) != 1 {
+		t.Fatalf("the command of user 100 was executed %v times", len(echo.executed))
+	}
+
+	u := textUpdate("/echo hi")
+	u.Message.Sender = &User{ID: 101}
+	dispatch(context.Background(), bot, u)
+	if len(echo.executed) != 1 {
+		t.Error("the middleware did not stop the command of user 101")
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	feat, err := applyMiddleware(&panickingFeature{fakeFeature: fakeFeature{name: "boom"}}, map[string][]string{"boom": {"recover"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handled, err := feat.Execute(context.Background(), &Bot{}, textUpdate("hello"))
+	var panicErr *panicError
+	if !handled || !errors.As(err, &panicErr) {
+		t.Errorf("the panic was not returned as an error: %v %v", handled, err)
+	}
+}
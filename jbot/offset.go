@@ -0,0 +1,219 @@
+package jbot
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOffsetFile     = "update_offset"
+	offsetSaveInterval    = 5 * time.Second
+	offsetFilePermissions = 0644
+)
+
+// offsetStore keeps the ID of the last processed update over restarts.
+// String describes where the offset is kept.
+type offsetStore interface {
+	load() (int, error)
+	save(offset int) error
+	String() string
+}
+
+// newOffsetStore returns a store that uses the update_offset table
+// if the database has it, and the file at path otherwise.
+func newOffsetStore(db *sql.DB, path string) offsetStore {
+	if connected(db) {
+		var tableExists bool
+		err := db.QueryRow("SELECT EXISTS (SELECT * FROM update_offset)").Scan(&tableExists)
+		if err == nil {
+			return &dbOffsetStore{db}
+		}
+	}
+
+	if path == "" {
+		path = defaultOffsetFile
+	}
+	return &fileOffsetStore{path}
+}
+
+// dbOffsetStore stores the offset in the single row of the update_offset table.
+type dbOffsetStore struct {
+	db *sql.DB
+}
+
+func (s *dbOffsetStore) load() (int, error) {
+	var offset int
+	err := s.db.QueryRow("SELECT update_id FROM update_offset WHERE id = 1").Scan(&offset)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return offset, err
+}
+
+func (s *dbOffsetStore) save(offset int) error {
+	_, err := s.db.Exec("INSERT INTO update_offset (id, update_id) VALUES (1, $1) ON CONFLICT (id) DO UPDATE SET update_id = $1", offset)
+	return err
+}
+
+func (s *dbOffsetStore) String() string {
+	return "database"
+}
+
+// fileOffsetStore stores the offset as text in a file.
+type fileOffsetStore struct {
+	path string
+}
+
+func (s *fileOffsetStore) load() (int, error) {
+	content, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(content)))
+}
+
+// save writes the offset to a temporary file first,
+// so that a crash can't leave a half written file behind.
+func (s *fileOffsetStore) save(offset int) error {
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.Itoa(offset)+"\n"), offsetFilePermissions); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *fileOffsetStore) String() string {
+	return "file " + s.path
+}
+
+// offsetTracker keeps track of the updates that are being handled.
+// The offset is the highest update ID such that it and every update
+// before it that was seen have been handled. Updates that were seen
+// recently or that are older than the offset the bot resumed from
+// are rejected as duplicates. Other updates are accepted even if they
+// are older than the current offset, because a webhook can deliver
+// updates out of order.
+type offsetTracker struct {
+	mu      sync.Mutex
+	resumed int          // the offset when the bot started
+	handled int          // the offset
+	pending map[int]bool // updates that are being handled
+	done    map[int]bool // updates after the offset that have been handled
+	seen    map[int]bool // the last seenUpdates updates
+	order   []int        // the keys of seen in the order they were seen
+}
+
+// seenUpdates is the number of updates remembered to detect duplicates.
+const seenUpdates = 1000
+
+func newOffsetTracker(offset int) *offsetTracker {
+	return &offsetTracker{
+		resumed: offset,
+		handled: offset,
+		pending: make(map[int]bool),
+		done:    make(map[int]bool),
+		seen:    make(map[int]bool),
+	}
+}
+
+// begin marks the update id as being handled.
+// It returns false if id is a duplicate.
+func (t *offsetTracker) begin(id int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if id <= t.resumed || t.seen[id] {
+		return false
+	}
+
+	t.seen[id] = true
+	t.order = append(t.order, id)
+	if len(t.order) > seenUpdates {
+		delete(t.seen, t.order[0])
+		t.order = t.order[1:]
+	}
+
+	t.pending[id] = true
+	return true
+}
+
+// finish marks the update id as handled and moves the offset forward.
+func (t *offsetTracker) finish(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.pending[id] {
+		return
+	}
+	delete(t.pending, id)
+	if id > t.handled {
+		t.done[id] = true
+	}
+
+	// the offset stops before the oldest update that is still pending
+	oldestPending := 0
+	for pendingID := range t.pending {
+		if oldestPending == 0 || pendingID < oldestPending {
+			oldestPending = pendingID
+		}
+	}
+	for doneID := range t.done {
+		if oldestPending == 0 || doneID < oldestPending {
+			if doneID > t.handled {
+				t.handled = doneID
+			}
+			delete(t.done, doneID)
+		}
+	}
+}
+
+// offset returns the offset.
+func (t *offsetTracker) offset() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.handled
+}
+
+// saveOffsets saves the offset of tracker to store every offsetSaveInterval
+// until ctx is done. saved is the offset that store already has.
+func saveOffsets(ctx context.Context, tracker *offsetTracker, store offsetStore, saved int, logger *Logger) {
+	ticker := time.NewTicker(offsetSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if offset := tracker.offset(); offset != saved {
+				if err := store.save(offset); err != nil {
+					logger.Error("failed to save update offset", "error", err)
+				} else {
+					saved = offset
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// loadOffset loads the offset from store. If it can't be loaded,
+// the bot starts from the updates that telegram still has.
+func loadOffset(store offsetStore, logger *Logger) int {
+	offset, err := store.load()
+	if err != nil {
+		logger.Error("failed to load update offset", "store", store, "error", err)
+		return 0
+	}
+	logger.Info("resuming after update", "update", offset, "store", store)
+	return offset
+}
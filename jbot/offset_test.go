@@ -0,0 +1,86 @@
+package jbot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	tracker := newOffsetTracker(10)
+
+	if tracker.begin(9) || tracker.begin(10) {
+		t.Error("an update from before the restart was accepted")
+	}
+	if !tracker.begin(11) || !tracker.begin(12) || !tracker.begin(13) {
+		t.Fatal("new updates were rejected")
+	}
+	if tracker.begin(12) {
+		t.Error("a duplicate update was accepted")
+	}
+
+	// 11 is still being handled, so the offset can't move past it
+	tracker.finish(12)
+	if offset := tracker.offset(); offset != 10 {
+		t.Errorf("expected offset 10, got %v", offset)
+	}
+
+	tracker.finish(11)
+	if offset := tracker.offset(); offset != 12 {
+		t.Errorf("expected offset 12, got %v", offset)
+	}
+
+	tracker.finish(13)
+	if offset := tracker.offset(); offset != 13 {
+		t.Errorf("expected offset 13, got %v", offset)
+	}
+	if tracker.begin(13) {
+		t.Error("a handled update was accepted again")
+	}
+}
+
+func TestFileOffsetStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &fileOffsetStore{filepath.Join(dir, "update_offset")}
+	if offset, err := store.load(); offset != 0 || err != nil {
+		t.Errorf("a missing file did not give offset 0: %v, %v", offset, err)
+	}
+
+	if err = store.save(1234); err != nil {
+		t.Fatalf("saving failed: %v", err)
+	}
+	if offset, err := store.load(); offset != 1234 || err != nil {
+		t.Errorf("expected to load offset 1234, got %v, %v", offset, err)
+	}
+}
+
+func TestDBOffsetStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT update_id FROM update_offset").WillReturnRows(sqlmock.NewRows([]string{"update_id"}).AddRow(99))
+	mock.ExpectExec("^INSERT INTO update_offset").WithArgs(100).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	store := &dbOffsetStore{db}
+	if offset, err := store.load(); offset != 99 || err != nil {
+		t.Errorf("expected to load offset 99, got %v, %v", offset, err)
+	}
+	if err = store.save(100); err != nil {
+		t.Errorf("saving failed: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,333 @@
+package jbot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Telegram allows about 30 messages per second in total, one message
+// per second to a private chat and 20 messages per minute to a group.
+const (
+	defaultGlobalRate   = 30 // messages per second
+	defaultChatRate     = 1  // messages per second
+	defaultGroupRate    = 20 // messages per minute
+	defaultSendAttempts = 4
+	defaultSendBackoff  = time.Second
+
+	// defaultOutboxQueueSize is the number of replies
+	// that can wait to be sent to one chat
+	defaultOutboxQueueSize = 100
+
+	// chatPacerSweepSize is the number of chat queues
+	// after which idle ones are forgotten
+	chatPacerSweepSize = 1000
+)
+
+// RateLimitConfig holds the limits of the messages the bot sends.
+// Zero values are replaced with Telegram's limits.
+type RateLimitConfig struct {
+	Global   int `json:"global"`   // messages per second to all chats together
+	Chat     int `json:"chat"`     // messages per second to a private chat
+	Group    int `json:"group"`    // messages per minute to a group
+	Attempts int `json:"attempts"` // times a message is tried before it is dropped
+	Queue    int `json:"queue"`    // messages that can wait to be sent to one chat
+}
+
+// floodError is returned by a messenger when the chat
+// service asks the bot to wait before sending again.
+type floodError struct {
+	retryAfter time.Duration
+	err        error
+}
+
+func (e *floodError) Error() string {
+	return e.err.Error()
+}
+
+// temporaryError is returned by a messenger when
+// sending may succeed if it is tried again.
+type temporaryError struct {
+	err error
+}
+
+func (e *temporaryError) Error() string {
+	return e.err.Error()
+}
+
+// outbox is a messenger that queues the replies sent with the wrapped
+// messenger and sends them in the background, so that features don't
+// wait for the rate limits. Every chat has its own queue, which its own
+// goroutine drains while it has replies, spacing them by the limit of
+// the chat and the global limit. A reply that fails with a flood or
+// temporary error is tried again after a wait. Replies that can't be
+// sent are logged and counted as dropped.
+type outbox struct {
+	messenger
+	logger    *Logger // for the replies sent without the logger of an update
+	stats     *runnerStats
+	attempts  int
+	backoff   time.Duration // wait after the first temporary error, doubled every time
+	queueSize int           // max number of replies waiting per chat
+
+	global        *pacer
+	chatInterval  time.Duration
+	groupInterval time.Duration
+
+	ctx    context.Context // cancelled when the outbox gives up on the queued replies
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	chats  map[int64]*chatQueue
+	closed bool
+}
+
+// chatQueue holds the replies waiting to be sent to a chat.
+type chatQueue struct {
+	pacer    *pacer
+	replies  []queuedReply
+	draining bool // true while a goroutine sends the replies
+}
+
+// queuedReply is a reply waiting in a chatQueue.
+type queuedReply struct {
+	reply  Reply
+	logger *Logger
+	sent   func(Reply, int) // called with the ID of the message once it is sent, may be nil
+}
+
+type sentHookKey struct{}
+
+// withSentHook returns a copy of ctx in which a messenger that sends
+// replies in the background calls hook with each reply and the ID of
+// its message once it has been sent.
+func withSentHook(ctx context.Context, hook func(Reply, int)) context.Context {
+	return context.WithValue(ctx, sentHookKey{}, hook)
+}
+
+func newOutbox(m messenger, cfg RateLimitConfig, logger *Logger, stats *runnerStats) *outbox {
+	if cfg.Global <= 0 {
+		cfg.Global = defaultGlobalRate
+	}
+	if cfg.Chat <= 0 {
+		cfg.Chat = defaultChatRate
+	}
+	if cfg.Group <= 0 {
+		cfg.Group = defaultGroupRate
+	}
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = defaultSendAttempts
+	}
+	if cfg.Queue <= 0 {
+		cfg.Queue = defaultOutboxQueueSize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &outbox{
+		messenger:     m,
+		logger:        logger,
+		stats:         stats,
+		attempts:      cfg.Attempts,
+		backoff:       defaultSendBackoff,
+		queueSize:     cfg.Queue,
+		global:        &pacer{interval: time.Second / time.Duration(cfg.Global)},
+		chatInterval:  time.Second / time.Duration(cfg.Chat),
+		groupInterval: time.Minute / time.Duration(cfg.Group),
+		ctx:           ctx,
+		cancel:        cancel,
+		chats:         make(map[int64]*chatQueue),
+	}
+}
+
+// send queues r to be sent in the background and returns at once.
+// The message ID is 0, because the message has not been sent yet.
+// It returns an error only if r was dropped because the queue of
+// its chat is full or the outbox is closed.
+func (o *outbox) send(ctx context.Context, r Reply) (int, error) {
+	q := queuedReply{reply: r, logger: contextLogger(ctx, o.logger)}
+	q.sent, _ = ctx.Value(sentHookKey{}).(func(Reply, int))
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return 0, o.drop(q, errors.New("the bot is shutting down"))
+	}
+	chat := o.chatQueue(r.ChatID)
+	if len(chat.replies) >= o.queueSize {
+		return 0, o.drop(q, errors.New("too many replies waiting for the chat"))
+	}
+
+	chat.replies = append(chat.replies, q)
+	if !chat.draining {
+		chat.draining = true
+		o.wg.Add(1)
+		go o.drain(chat)
+	}
+	return 0, nil
+}
+
+// drain sends the replies of chat in order until none are left.
+func (o *outbox) drain(chat *chatQueue) {
+	defer o.wg.Done()
+
+	for {
+		o.mu.Lock()
+		if len(chat.replies) == 0 {
+			chat.draining = false
+			o.mu.Unlock()
+			return
+		}
+		q := chat.replies[0]
+		chat.replies = chat.replies[1:]
+		o.mu.Unlock()
+
+		o.deliver(chat.pacer, q)
+	}
+}
+
+// deliver waits for the turn of q and sends it, trying again after
+// flood and temporary errors. It gives up when the outbox gives up
+// or the attempts run out.
+func (o *outbox) deliver(chat *pacer, q queuedReply) {
+	backoff := o.backoff
+
+	var err error
+	for attempt := 1; attempt <= o.attempts; attempt++ {
+		if err = chat.wait(o.ctx); err != nil {
+			break
+		}
+		if err = o.global.wait(o.ctx); err != nil {
+			break
+		}
+
+		var messageID int
+		messageID, err = o.messenger.send(o.ctx, q.reply)
+		switch e := err.(type) {
+		case nil:
+			if q.sent != nil {
+				q.sent(q.reply, messageID)
+			}
+			return
+		case *floodError:
+			q.logger.Warn("flood limit reached, waiting", "chat", q.reply.ChatID, "wait", e.retryAfter)
+			chat.delay(e.retryAfter)
+			continue
+		case *temporaryError:
+			chat.delay(backoff)
+			backoff *= 2
+			continue
+		}
+		break
+	}
+
+	o.drop(q, err)
+}
+
+// drop logs and counts q as dropped and returns err.
+func (o *outbox) drop(q queuedReply, err error) error {
+	o.stats.messageDropped()
+	q.logger.Error("dropping message", "chat", q.reply.ChatID, "error", err)
+	return err
+}
+
+// close stops accepting replies and waits at most timeout for the
+// queued ones to be sent. The replies that are still waiting after
+// that are dropped. It returns false if they did not all get sent.
+func (o *outbox) close(timeout time.Duration) bool {
+	o.mu.Lock()
+	o.closed = true
+	o.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		o.cancel()
+		<-done
+		return false
+	}
+}
+
+// chatQueue returns the queue of chatID. Group chats have negative IDs.
+// It must be called with o.mu held.
+func (o *outbox) chatQueue(chatID int64) *chatQueue {
+	if chat, ok := o.chats[chatID]; ok {
+		return chat
+	}
+
+	if len(o.chats) >= chatPacerSweepSize {
+		now := time.Now()
+		for id, chat := range o.chats {
+			if !chat.draining && chat.pacer.idle(now) {
+				delete(o.chats, id)
+			}
+		}
+	}
+
+	p := &pacer{interval: o.chatInterval}
+	if chatID < 0 {
+		p.interval = o.groupInterval
+	}
+	chat := &chatQueue{pacer: p}
+	o.chats[chatID] = chat
+	return chat
+}
+
+// pacer spaces events at least interval apart.
+// Callers get their turns in the order they called wait.
+type pacer struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // the earliest time of the next event
+}
+
+// wait books the next turn and sleeps until it. It returns
+// an error if ctx is done first, in which case the turn is lost.
+func (p *pacer) wait(ctx context.Context) error {
+	p.mu.Lock()
+	now := time.Now()
+	turn := p.next
+	if turn.Before(now) {
+		turn = now
+	}
+	p.next = turn.Add(p.interval)
+	p.mu.Unlock()
+
+	timer := time.NewTimer(turn.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay pushes the next turn at least d into the future.
+func (p *pacer) delay(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if until := time.Now().Add(d); p.next.Before(until) {
+		p.next = until
+	}
+}
+
+// idle returns true if the next turn is free at now.
+func (p *pacer) idle(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return !p.next.After(now)
+}
@@ -0,0 +1,236 @@
+package jbot
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+)
+
+// failingMessenger is a recordingMessenger whose sends
+// fail with the errors in errs until they run out.
+type failingMessenger struct {
+	recordingMessenger
+	errs     []error
+	attempts int
+}
+
+func (m *failingMessenger) send(ctx context.Context, r Reply) (int, error) {
+	m.attempts++
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return 0, err
+	}
+	return m.recordingMessenger.send(ctx, r)
+}
+
+func testOutbox(m messenger) *outbox {
+	o := newOutbox(m, RateLimitConfig{Global: 1000, Chat: 1000, Group: 60000}, newLogger(log.New(ioutil.Discard, "", 0)), newRunnerStats())
+	o.backoff = time.Millisecond
+	return o
+}
+
+// blockingMessenger is a recordingMessenger whose sends to
+// the chat blocked wait until release is closed.
+type blockingMessenger struct {
+	recordingMessenger
+	blocked int64
+	release chan struct{}
+}
+
+func (m *blockingMessenger) send(ctx context.Context, r Reply) (int, error) {
+	if r.ChatID == m.blocked {
+		<-m.release
+	}
+	return m.recordingMessenger.send(ctx, r)
+}
+
+// sent returns the replies sent so far.
+func (m *blockingMessenger) sent() []Reply {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Reply{}, m.replies...)
+}
+
+// waitUntilSending waits until the first reply queued to chatID is being sent.
+func waitUntilSending(o *outbox, chatID int64) {
+	for {
+		o.mu.Lock()
+		waiting := len(o.chats[chatID].replies)
+		o.mu.Unlock()
+		if waiting == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOutboxSendsInBackground(t *testing.T) {
+	m := &blockingMessenger{blocked: -1, release: make(chan struct{})}
+	o := testOutbox(m)
+
+	start := time.Now()
+	for _, r := range []Reply{{ChatID: -1, Text: "1"}, {ChatID: -1, Text: "2"}, {ChatID: 1, Text: "3"}} {
+		if _, err := o.send(context.Background(), r); err != nil {
+			t.Fatalf("send failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("send waited %v for the reply to be sent", elapsed)
+	}
+
+	// the blocked group does not hold back the other chat
+	deadline := time.Now().Add(time.Second)
+	for len(m.sent()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if sent := m.sent(); len(sent) != 1 || sent[0].Text != "3" {
+		t.Fatalf("expected only the reply to the other chat, got %v", sent)
+	}
+
+	close(m.release)
+	if !o.close(time.Second) {
+		t.Fatal("the queued replies were not sent")
+	}
+	if sent := m.sent(); len(sent) != 3 || sent[1].Text != "1" || sent[2].Text != "2" {
+		t.Errorf("the replies to the group were not sent in order: %v", sent)
+	}
+	if _, err := o.send(context.Background(), Reply{ChatID: 1, Text: "late"}); err == nil {
+		t.Error("a closed outbox accepted a reply")
+	}
+}
+
+func TestOutboxCallsSentHook(t *testing.T) {
+	o := testOutbox(&recordingMessenger{})
+
+	var sent []int
+	ctx := withSentHook(context.Background(), func(r Reply, messageID int) {
+		sent = append(sent, messageID)
+	})
+	o.send(ctx, Reply{ChatID: 1, Text: "hi"})
+	o.send(ctx, Reply{ChatID: 1, Text: "hi again"})
+	o.close(time.Second)
+
+	if len(sent) != 2 || sent[0] != 1 || sent[1] != 2 {
+		t.Errorf("unexpected message IDs %v", sent)
+	}
+}
+
+func TestOutboxDropsWhenChatQueueIsFull(t *testing.T) {
+	m := &blockingMessenger{blocked: 1, release: make(chan struct{})}
+	o := testOutbox(m)
+	o.queueSize = 2
+
+	o.send(context.Background(), Reply{ChatID: 1, Text: "hi"})
+	waitUntilSending(o, 1)
+	var errs int
+	for i := 0; i < 3; i++ {
+		if _, err := o.send(context.Background(), Reply{ChatID: 1, Text: "hi"}); err != nil {
+			errs++
+		}
+	}
+	close(m.release)
+	o.close(time.Second)
+
+	// the first reply is being sent and two wait, so one does not fit
+	if dropped := o.stats.droppedMessages.Value(); errs != 1 || dropped != 1 {
+		t.Errorf("expected 1 dropped message, got %v errors and %v dropped", errs, dropped)
+	}
+}
+
+func TestOutboxRetries(t *testing.T) {
+	m := &failingMessenger{errs: []error{
+		&floodError{10 * time.Millisecond, errors.New("Too Many Requests: retry after 1")},
+		&temporaryError{errors.New("connection reset")},
+	}}
+	o := testOutbox(m)
+
+	start := time.Now()
+	if _, err := o.send(context.Background(), Reply{ChatID: 1, Text: "hi"}); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	o.close(time.Second)
+	if m.attempts != 3 || len(m.replies) != 1 {
+		t.Errorf("expected 3 attempts and 1 reply, got %v and %v", m.attempts, len(m.replies))
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Error("retry_after was not honoured")
+	}
+}
+
+func TestOutboxDropsAfterAttempts(t *testing.T) {
+	permanent := errors.New("Forbidden: bot was blocked by the user")
+	m := &failingMessenger{errs: []error{permanent}}
+	o := testOutbox(m)
+
+	o.send(context.Background(), Reply{ChatID: 1, Text: "hi"})
+	o.close(time.Second)
+	if m.attempts != 1 || o.stats.droppedMessages.Value() != 1 {
+		t.Errorf("a permanent error was retried %v times and dropped %v times", m.attempts-1, o.stats.droppedMessages.Value())
+	}
+
+	m = &failingMessenger{}
+	for i := 0; i < defaultSendAttempts; i++ {
+		m.errs = append(m.errs, &temporaryError{errors.New("timeout")})
+	}
+	o = testOutbox(m)
+	o.send(context.Background(), Reply{ChatID: 1, Text: "hi"})
+	o.close(time.Second)
+	if m.attempts != defaultSendAttempts || len(m.replies) != 0 || o.stats.droppedMessages.Value() != 1 {
+		t.Errorf("expected %v attempts, no replies and 1 dropped message, got %v, %v and %v",
+			defaultSendAttempts, m.attempts, len(m.replies), o.stats.droppedMessages.Value())
+	}
+}
+
+func TestOutboxLogsWithUpdateLogger(t *testing.T) {
+	l, buf := bufferLogger(LogConfig{Format: "json"})
+	o := testOutbox(&failingMessenger{errs: []error{errors.New("Forbidden: bot was blocked by the user")}})
+
+	u := textUpdate("hi")
+	ctx := withLogger(context.Background(), updateLogger(l, u).With("feature", "greeter"))
+	o.send(ctx, Reply{ChatID: u.ChatID(), Text: "hello"})
+	o.close(time.Second)
+
+	lines := logLines(t, buf)
+	if len(lines) != 1 || lines[0]["msg"] != "dropping message" || lines[0]["update"] != 1.0 || lines[0]["user"] != 100.0 || lines[0]["feature"] != "greeter" {
+		t.Errorf("the dropped message was not logged with the fields of the update: %v", lines)
+	}
+}
+
+func TestOutboxCloseGivesUp(t *testing.T) {
+	m := &failingMessenger{errs: []error{&floodError{time.Hour, errors.New("Too Many Requests: retry after 3600")}}}
+	o := testOutbox(m)
+
+	o.send(context.Background(), Reply{ChatID: 1, Text: "hi"})
+	start := time.Now()
+	if o.close(10 * time.Millisecond) {
+		t.Error("close reported a reply waiting for an hour as sent")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("close took %v", elapsed)
+	}
+}
+
+func TestPacerSpacesTurns(t *testing.T) {
+	p := &pacer{interval: 5 * time.Millisecond}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := p.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("4 turns took only %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.delay(time.Hour)
+	if err := p.wait(ctx); err == nil {
+		t.Error("wait did not stop when the context was done")
+	}
+}
@@ -0,0 +1,116 @@
+package jbot
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+const defaultPanicLimit = 3
+
+// panicError is the error returned for a panic in a feature.
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// callTriggers calls feat.Triggers(u). If it panics,
+// the panic is returned as an error.
+func callTriggers(feat Feature, u Update) (triggered bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			triggered, err = false, &panicError{r, debug.Stack()}
+		}
+	}()
+	return feat.Triggers(u), nil
+}
+
+// callExecute calls feat.Execute. If it panics, the update
+// counts as handled and the panic is returned as an error.
+func callExecute(ctx context.Context, bot *Bot, feat Feature, u Update) (handled bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			handled, err = true, &panicError{r, debug.Stack()}
+		}
+	}()
+	return feat.Execute(ctx, bot, u)
+}
+
+// panicGuard disables the features that panic limit times in a row.
+// Features that are disabled stay so until the config is reloaded.
+// A nil panicGuard never disables features.
+type panicGuard struct {
+	limit  int // panics in a row that disable a feature, non-positive for never
+	logger *Logger
+
+	mu       sync.Mutex
+	inARow   map[string]int
+	disabled map[string]bool
+}
+
+func newPanicGuard(limit int, logger *Logger) *panicGuard {
+	if limit == 0 {
+		limit = defaultPanicLimit
+	}
+	return &panicGuard{
+		limit:    limit,
+		logger:   logger,
+		inARow:   make(map[string]int),
+		disabled: make(map[string]bool),
+	}
+}
+
+// isDisabled returns true if the feature called name has been disabled.
+func (g *panicGuard) isDisabled(name string) bool {
+	if g == nil {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.disabled[name]
+}
+
+// record counts err if it is a panic of the feature called name
+// and disables the feature if it has panicked too many times in a row.
+// Any other outcome resets the count.
+func (g *panicGuard) record(name string, err error) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := err.(*panicError); !ok {
+		delete(g.inARow, name)
+		return
+	}
+
+	g.inARow[name]++
+	if g.limit > 0 && g.inARow[name] >= g.limit && !g.disabled[name] {
+		g.disabled[name] = true
+		g.logger.Error("disabling feature after panics in a row, reload the config to enable it again", "feature", name, "panics", g.inARow[name])
+	}
+}
+
+// describeUpdate returns a short description of the contents of u for the log.
+func describeUpdate(u Update) string {
+	switch {
+	case u.Message != nil:
+		return fmt.Sprintf("%v %q", u.kind(), u.Message.Text)
+	case u.Callback != nil:
+		return fmt.Sprintf("callback %q", u.Callback.Data)
+	case u.Inline != nil:
+		return fmt.Sprintf("inline query %q", u.Inline.Query)
+	case u.BotMember != nil:
+		return fmt.Sprintf("bot status %q → %q", u.BotMember.OldStatus, u.BotMember.NewStatus)
+	}
+	return "empty update"
+}
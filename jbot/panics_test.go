@@ -0,0 +1,77 @@
+package jbot
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+// panickingFeature panics in triggers or execute.
+type panickingFeature struct {
+	fakeFeature
+	inTriggers bool
+}
+
+func (f *panickingFeature) Triggers(u Update) bool {
+	if f.inTriggers {
+		panic("triggers exploded")
+	}
+	return true
+}
+
+func (f *panickingFeature) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	panic("execute exploded")
+}
+
+func TestDispatchRecoversPanics(t *testing.T) {
+	inTriggers := &panickingFeature{fakeFeature: fakeFeature{name: "panics_in_triggers"}, inTriggers: true}
+	next := &fakeFeature{name: "next", trigger: true, handle: true}
+	bot := &Bot{features: []Feature{inTriggers, next}}
+
+	dispatch(context.Background(), bot, textUpdate("hello"))
+	if len(next.executed) != 1 {
+		t.Error("a panic in triggers stopped the next feature")
+	}
+
+	inExecute := &panickingFeature{fakeFeature: fakeFeature{name: "panics_in_execute"}}
+	bot = &Bot{features: []Feature{inExecute}, stats: newRunnerStats()}
+	dispatch(context.Background(), bot, textUpdate("hello"))
+
+	if count := bot.stats.featurePanics.Get("panics_in_execute"); count == nil || count.String() != "1" {
+		t.Errorf("expected 1 panic, got %v", count)
+	}
+}
+
+func TestPanicGuardDisablesFeature(t *testing.T) {
+	panicky := &panickingFeature{fakeFeature: fakeFeature{name: "panics_often"}}
+	next := &fakeFeature{name: "next", trigger: true, handle: true}
+	bot := &Bot{
+		features: []Feature{panicky, next},
+		panics:   newPanicGuard(2, newLogger(log.New(ioutil.Discard, "", 0))),
+	}
+
+	for i := 0; i < 3; i++ {
+		dispatch(context.Background(), bot, textUpdate("hello"))
+	}
+
+	if !bot.panics.isDisabled("panics_often") {
+		t.Error("a feature that panicked twice in a row was not disabled")
+	}
+	// the first two updates were handled by the panics, the third reached next
+	if len(next.executed) != 1 {
+		t.Errorf("expected next to execute once after panicky was disabled, got %v", len(next.executed))
+	}
+}
+
+func TestPanicGuardResetsOnSuccess(t *testing.T) {
+	g := newPanicGuard(2, newLogger(log.New(ioutil.Discard, "", 0)))
+
+	g.record("flaky", &panicError{value: "boom"})
+	g.record("flaky", nil)
+	g.record("flaky", &panicError{value: "boom"})
+
+	if g.isDisabled("flaky") {
+		t.Error("a feature was disabled although its panics were not in a row")
+	}
+}
@@ -2,13 +2,12 @@
 package jbot
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
 	"strings"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	gjson "github.com/tidwall/gjson"
 )
 
@@ -22,13 +21,14 @@ type pingpongFeature struct {
 	IsReply            bool     `json:"isreply"`            // if true, the telegram message is replying to the command (replying is a feature in telegram)
 	Pongs              []string `json:"pongs"`              // list of possible answers to command, random one will be sent
 	SuccessPropability float64  `json:"successpropability"` // 0.0-1.0 propability, used to make the command randomly fail
+	Description        string   `json:"description"`        // shown in the command menu for pings that are slash commands
 }
 
 func (p *pingpong) String() string {
 	return "pingpong"
 }
 
-func (p *pingpong) init(bot *jbot) error {
+func (p *pingpong) Init(bot *Bot) error {
 
 	jsonConfig := gjson.GetBytes(bot.cfg.Features, "pingpong")
 	if !jsonConfig.Exists() {
@@ -50,42 +50,102 @@ func (p *pingpong) init(bot *jbot) error {
 	return nil
 }
 
-func (p *pingpong) triggers(u tgbotapi.Update) bool {
+// Describe gives the pings that start a message as examples,
+// the other pings are left as surprises.
+func (p *pingpong) Describe() Description {
+	examples := []string{}
+	for _, feat := range p.features {
+		if feat.IsPrefixCommand {
+			examples = append(examples, feat.Pings...)
+		}
+	}
+	return Description{Examples: examples}
+}
+
+// menuCommands returns the pings that are slash commands.
+func (p *pingpong) menuCommands() []menuCommand {
+	commands := []menuCommand{}
+	for _, feat := range p.features {
+		if !feat.IsPrefixCommand {
+			continue
+		}
+		for _, ping := range feat.Pings {
+			if strings.HasPrefix(ping, "/") {
+				commands = append(commands, menuCommand{strings.TrimPrefix(ping, "/"), feat.Description})
+			}
+		}
+	}
+	return commands
+}
+
+// Commands returns the pings that are slash commands, so that they
+// match only the whole command and not commands to other bots.
+func (p *pingpong) Commands() []string {
+	commands := []string{}
+	for _, feat := range p.features {
+		if !feat.IsPrefixCommand {
+			continue
+		}
+		for _, ping := range feat.Pings {
+			if strings.HasPrefix(ping, "/") {
+				commands = append(commands, ping)
+			}
+		}
+	}
+	return commands
+}
+
+func (p *pingpong) Triggers(u Update) bool {
 	// any message will trigger
 	return u.Message != nil
 }
 
-func (p *pingpong) execute(bot *jbot, u tgbotapi.Update) error {
+// Execute sends the pongs of all matching pings. The update
+// counts as handled if at least one pong was sent.
+func (p *pingpong) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
 
+	handled := false
 	for _, feat := range p.features {
 
-		toSend := findPingpongReply(strings.ToLower(u.Message.Text), feat)
+		toSend := findPingpongReply(u, feat, bot.rand)
 		if toSend != "" {
 
-			msg := tgbotapi.NewMessage(u.Message.Chat.ID, toSend)
+			msg := Reply{ChatID: u.Message.Chat.ID, Text: toSend}
 			if feat.IsReply {
-				msg.ReplyToMessageID = u.Message.MessageID
+				msg.ReplyToMessageID = u.Message.ID
+			}
+			if _, err := bot.Send(ctx, msg); err != nil {
+				return true, err
 			}
-			bot.botAPI.Send(msg)
+			handled = true
 
 		}
 
 	}
-	return nil
+	return handled, nil
 }
 
-func findPingpongReply(text string, feature pingpongFeature) string {
+// findPingpongReply returns a random pong of feature if u has one of its
+// pings. A ping that is a slash command matches the command of u, other
+// prefix pings the start of the text and the rest anywhere in the text.
+func findPingpongReply(u Update, feature pingpongFeature, rnd *random) string {
 	reply := ""
+	text := strings.ToLower(u.Message.Text)
 
 	for _, keyword := range feature.Pings {
 
-		if feature.IsPrefixCommand {
+		if feature.IsPrefixCommand && strings.HasPrefix(keyword, "/") {
+			if u.Command != nil && u.Command.Name == strings.ToLower(keyword) {
+				reply = feature.Pongs[rnd.intn(len(feature.Pongs))]
+				break
+			}
+		} else if feature.IsPrefixCommand {
 			if strings.HasPrefix(text, keyword) {
-				reply = feature.Pongs[rand.Intn(len(feature.Pongs))]
+				reply = feature.Pongs[rnd.intn(len(feature.Pongs))]
 				break
 			}
 		} else if strings.Contains(text, keyword) {
-			reply = feature.Pongs[rand.Intn(len(feature.Pongs))]
+			reply = feature.Pongs[rnd.intn(len(feature.Pongs))]
 			break
 		}
 	}
@@ -95,7 +155,7 @@ func findPingpongReply(text string, feature pingpongFeature) string {
 	}
 
 	if feature.SuccessPropability > 0 && feature.SuccessPropability < 1 {
-		if rand.Float64() > feature.SuccessPropability {
+		if rnd.float64() > feature.SuccessPropability {
 			return "" // feature failed randomly due to SuccesPropability
 		}
 	}
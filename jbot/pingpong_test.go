@@ -0,0 +1,43 @@
+package jbot
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPingpongMatchesWholeCommands(t *testing.T) {
+	p := &pingpong{features: []pingpongFeature{
+		{Pings: []string{"/start"}, IsPrefixCommand: true, Pongs: []string{"started"}},
+		{Pings: []string{"hello"}, IsPrefixCommand: true, Pongs: []string{"hi"}},
+		{Pings: []string{"sausage"}, Pongs: []string{"sausages!"}},
+	}}
+	features := []Feature{p}
+	bot := &Bot{features: features, commands: newCommandTable(features), username: "juhannusbot"}
+
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"/start", "started"},
+		{"/START@juhannusbot now", "started"},
+		{"/start@otherbot", ""},
+		{"/starting", ""},
+		{"hello there", "hi"},
+		{"say hello", ""},
+		{"any sausages left?", "sausages!"},
+	}
+
+	for _, test := range tests {
+		m := &recordingMessenger{}
+		bot.messenger = m
+		dispatch(context.Background(), bot, textUpdate(test.text))
+
+		got := ""
+		if len(m.replies) > 0 {
+			got = m.replies[0].Text
+		}
+		if got != test.expected {
+			t.Errorf("%q got the pong %q, want %q", test.text, got, test.expected)
+		}
+	}
+}
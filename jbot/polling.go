@@ -0,0 +1,102 @@
+package jbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	pollTimeout    = 60 * time.Second
+	pollRetryDelay = 3 * time.Second
+)
+
+// pollUpdates gets the updates after offset with long polling
+// and passes them to submit until ctx is done. A webhook left behind
+// by a previous run is deleted first, because telegram refuses to
+// give updates to getUpdates while a webhook is set.
+func pollUpdates(ctx context.Context, botAPI *tgbotapi.BotAPI, offset int, submit func(Update) bool, logger *Logger) error {
+
+	allowedUpdates, err := json.Marshal(telegramAllowedUpdates)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("timeout", strconv.Itoa(int(pollTimeout/time.Second)))
+	params.Set("allowed_updates", string(allowedUpdates))
+
+	for {
+		_, err = botAPI.MakeRequest("deleteWebhook", url.Values{})
+		if err == nil {
+			break
+		}
+		logger.Warn("failed to delete webhook, retrying", "wait", pollRetryDelay, "error", err)
+		select {
+		case <-time.After(pollRetryDelay):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	for {
+		params.Set("offset", strconv.Itoa(offset+1))
+		updates, err := getUpdates(ctx, botAPI, params)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			logger.Warn("failed to get updates, retrying", "wait", pollRetryDelay, "error", err)
+			select {
+			case <-time.After(pollRetryDelay):
+			case <-ctx.Done():
+				return nil
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			if update.UpdateID > offset {
+				offset = update.UpdateID
+				submit(fromTelegramUpdate(update))
+			}
+		}
+	}
+}
+
+// getUpdates asks telegram for updates. It returns when the request
+// ends or ctx is done, whichever happens first.
+func getUpdates(ctx context.Context, botAPI *tgbotapi.BotAPI, params url.Values) ([]telegramUpdate, error) {
+	type result struct {
+		updates []telegramUpdate
+		err     error
+	}
+
+	// copied so that the request doesn't see later changes to params
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = append([]string{}, values...)
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		response, err := botAPI.MakeRequest("getUpdates", query)
+		if err == nil {
+			err = json.Unmarshal(response.Result, &r.updates)
+		}
+		r.err = err
+		done <- r
+	}()
+
+	select {
+	case r := <-done:
+		return r.updates, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
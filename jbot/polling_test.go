@@ -0,0 +1,69 @@
+package jbot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// telegramTransport answers the requests of a BotAPI with handle
+// and records the methods that were called.
+type telegramTransport struct {
+	handle func(method string) string
+
+	mu      sync.Mutex
+	methods []string
+}
+
+func (t *telegramTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	method := path.Base(r.URL.Path)
+	t.mu.Lock()
+	t.methods = append(t.methods, method)
+	t.mu.Unlock()
+
+	recorder := httptest.NewRecorder()
+	recorder.WriteString(t.handle(method))
+	return recorder.Result(), nil
+}
+
+func (t *telegramTransport) called() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string{}, t.methods...)
+}
+
+func TestPollUpdatesDeletesWebhookFirst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transport := &telegramTransport{handle: func(method string) string {
+		if method == "getUpdates" {
+			return `{"ok": true, "result": [{"update_id": 8, "message": {"message_id": 1, "chat": {"id": 1000}, "text": "hello"}}]}`
+		}
+		return `{"ok": true, "result": true}`
+	}}
+	botAPI := &tgbotapi.BotAPI{Token: "token", Client: &http.Client{Transport: transport}}
+
+	var submitted []Update
+	submit := func(u Update) bool {
+		submitted = append(submitted, u)
+		cancel()
+		return true
+	}
+	if err := pollUpdates(ctx, botAPI, 7, submit, defaultLogger); err != nil {
+		t.Fatal(err)
+	}
+
+	methods := transport.called()
+	if len(methods) < 2 || methods[0] != "deleteWebhook" || methods[1] != "getUpdates" {
+		t.Errorf("expected deleteWebhook before getUpdates, got %v", methods)
+	}
+	if len(submitted) != 1 || submitted[0].ID != 8 {
+		t.Errorf("unexpected updates %+v", submitted)
+	}
+}
@@ -0,0 +1,38 @@
+package jbot
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// random is a source of random numbers that is safe for concurrent use.
+// Every Runner has its own. A nil random uses the source of math/rand.
+type random struct {
+	mu     sync.Mutex
+	source *rand.Rand
+}
+
+func newRandom() *random {
+	return &random{source: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// intn returns a random number in [0, n).
+func (r *random) intn(n int) int {
+	if r == nil {
+		return rand.Intn(n)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.source.Intn(n)
+}
+
+// float64 returns a random number in [0.0, 1.0).
+func (r *random) float64() float64 {
+	if r == nil {
+		return rand.Float64()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.source.Float64()
+}
@@ -0,0 +1,122 @@
+package jbot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	gjson "github.com/tidwall/gjson"
+)
+
+var (
+	registryMu sync.Mutex
+	registry   []func() Feature // constructors of the registered features in order
+	registered = make(map[string]bool)
+)
+
+// init registers the built-in features.
+// Features with equal priorities run in this order.
+func init() {
+	Register(func() Feature { return new(toggles) })
+	Register(func() Feature { return new(language) })
+	Register(func() Feature { return new(help) })
+	Register(func() Feature { return new(inline) })
+	Register(func() Feature { return new(decide) })
+	Register(func() Feature { return new(horoscope) })
+	Register(func() Feature { return new(wisdom) })
+	Register(func() Feature { return new(pingpong) })
+}
+
+// Register makes a feature available to the bot. It is meant to be called
+// from the init function of the package that provides the feature.
+// newFeature must return a new, uninitialised feature every time it is
+// called, because the features are created again when the config is reloaded.
+// Features with equal priorities run in the order they were registered.
+// Register panics if a feature with the same name is already registered.
+func Register(newFeature func() Feature) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if newFeature == nil {
+		panic("jbot: Register called with a nil constructor")
+	}
+	name := newFeature().String()
+	if registered[name] {
+		panic("jbot: Register called twice for feature " + name)
+	}
+
+	registered[name] = true
+	registry = append(registry, newFeature)
+}
+
+// registeredFeatures returns the constructors of the registered features.
+func registeredFeatures() []func() Feature {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	return append([]func() Feature{}, registry...)
+}
+
+// FeatureConfig returns the config of the feature called name, that is
+// the value of features.name in config.json. It returns nil if there is none.
+func (bot *Bot) FeatureConfig(name string) json.RawMessage {
+	jsonConfig := gjson.GetBytes(bot.cfg.Features, name)
+	if !jsonConfig.Exists() {
+		return nil
+	}
+	return json.RawMessage(jsonConfig.Raw)
+}
+
+// Database returns the database of the bot. The database may not be
+// connected, so features that need it should check that in Init.
+func (bot *Bot) Database() *sql.DB {
+	return bot.database
+}
+
+// HTTPClient returns the HTTP client that features should use
+// for their requests.
+func (bot *Bot) HTTPClient() *http.Client {
+	if bot.httpClient == nil {
+		return http.DefaultClient
+	}
+	return bot.httpClient
+}
+
+// Logger returns a standard logger that writes its lines to the log
+// of the bot at the info level. Log has levels and fields.
+func (bot *Bot) Logger() *log.Logger {
+	return bot.Log(context.Background()).std()
+}
+
+// Send sends r and returns the ID of the sent message. When an edited
+// command runs again, its replies edit the replies to the earlier version.
+// A bot connected to Telegram queues r and sends it in the background
+// within the rate limits; then the ID is 0 and the errors of sending
+// are logged instead of returned.
+func (bot *Bot) Send(ctx context.Context, r Reply) (int, error) {
+	origin, _ := ctx.Value(replyOriginKey{}).(*replyOrigin)
+	if origin != nil {
+		r = origin.prepare(r)
+		ctx = withSentHook(ctx, origin.record)
+	}
+
+	messageID, err := bot.messenger.send(ctx, r)
+	if origin != nil && err == nil {
+		origin.record(r, messageID)
+	}
+	return messageID, err
+}
+
+// AnswerCallback tells the user that pressed a button that their
+// callback was received. text is shown to the user if not empty.
+func (bot *Bot) AnswerCallback(ctx context.Context, callbackID string, text string) error {
+	return bot.messenger.answerCallback(ctx, callbackID, text)
+}
+
+// IsAdmin returns true if userID is an administrator of chatID.
+func (bot *Bot) IsAdmin(ctx context.Context, chatID int64, userID int64) (bool, error) {
+	return bot.messenger.isAdmin(ctx, chatID, userID)
+}
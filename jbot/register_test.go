@@ -0,0 +1,60 @@
+package jbot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// helloFeature is a feature defined the way a third-party package would.
+type helloFeature struct {
+	greeting string
+}
+
+func (h *helloFeature) String() string {
+	return "hello_test"
+}
+
+func (h *helloFeature) Init(bot *Bot) error {
+	h.greeting = string(bot.FeatureConfig("hello_test"))
+	return nil
+}
+
+func (h *helloFeature) Triggers(u Update) bool {
+	return u.Message != nil && u.Message.Text == "hello"
+}
+
+func (h *helloFeature) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	_, err := bot.Send(ctx, Reply{ChatID: u.ChatID(), Text: h.greeting})
+	return true, err
+}
+
+func TestRegister(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	Register(func() Feature { return new(helloFeature) })
+
+	m := &recordingMessenger{}
+	r := &Runner{messenger: m, db: db, logger: defaultLogger}
+	bot := r.newBot(Config{Features: []byte(`{"hello_test": "hi there"}`)}, nil)
+	dispatch(context.Background(), bot, textUpdate("hello"))
+
+	if len(m.replies) != 1 || m.replies[0].Text != `"hi there"` {
+		t.Errorf("registered feature did not reply with its config: %+v", m.replies)
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a feature name twice did not panic")
+		}
+	}()
+
+	Register(func() Feature { return new(decide) })
+}
@@ -0,0 +1,90 @@
+package jbot
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// Reload replaces the features of r with new ones initialised from cfg.
+// Updates that are already being handled finish with the old features.
+// Settings that can't change without a restart keep their old values
+// and a warning is logged for them. If cfg is invalid, Reload returns
+// an error and the old config stays in use.
+func (r *Runner) Reload(cfg Config) error {
+	if err := checkConfig(cfg, r.console); err != nil {
+		return err
+	}
+
+	messages, err := loadCatalog(cfg.Locales)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.current.Load().(*Bot)
+	for _, setting := range restartRequired(*old.cfg, cfg) {
+		r.logger.Warn("changing this setting takes effect after a restart", "setting", setting)
+	}
+
+	r.logger.configure(cfg.Log)
+	bot := r.newBot(cfg, messages)
+	r.logFeatures(bot)
+	r.current.Store(bot)
+	r.work.switchTo(bot)
+	r.schedule.register(bot)
+	r.logger.Info("config reloaded")
+	go r.syncCommands(bot)
+	return nil
+}
+
+// restartRequired returns the names of the settings that differ
+// between old and new but can't be changed without a restart.
+func restartRequired(old, new Config) []string {
+	settings := []string{}
+	if old.APIKey != new.APIKey {
+		settings = append(settings, "apikey")
+	}
+	if old.DatabaseURL != new.DatabaseURL {
+		settings = append(settings, "databaseurl")
+	}
+	if old.Workers != new.Workers || old.QueueSize != new.QueueSize || old.ChatQueueSize != new.ChatQueueSize {
+		settings = append(settings, "workers and queue sizes")
+	}
+	if old.ShutdownTimeout != new.ShutdownTimeout {
+		settings = append(settings, "shutdowntimeout")
+	}
+	if old.RetryInterval != new.RetryInterval {
+		settings = append(settings, "retryinterval")
+	}
+	if old.Webhook != new.Webhook {
+		settings = append(settings, "webhook")
+	}
+	if old.RateLimit != new.RateLimit {
+		settings = append(settings, "ratelimit")
+	}
+	if old.OffsetFile != new.OffsetFile {
+		settings = append(settings, "offsetfile")
+	}
+	return settings
+}
+
+// onSignal calls f every time the process receives one of the signals,
+// until ctx is done. Received signals are logged to logger.
+func onSignal(ctx context.Context, logger *Logger, f func(), signals ...os.Signal) {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+
+	for {
+		select {
+		case s := <-received:
+			logger.Info("received signal", "signal", s)
+			f()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
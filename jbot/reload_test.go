@@ -0,0 +1,74 @@
+package jbot
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// runningFeatures returns the names of the features of bot.
+func runningFeatures(bot *Bot) map[string]bool {
+	names := make(map[string]bool)
+	for _, feat := range bot.features {
+		names[feat.String()] = true
+	}
+	return names
+}
+
+func TestReload(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	oldConfig := Config{Features: []byte(`{"decide": {"aliases": ["/decide"]}}`)}
+	newConfig := Config{Features: []byte(`{"pingpong": [{"pings": ["ping"], "pongs": ["pong"]}]}`)}
+
+	r := &Runner{messenger: &recordingMessenger{}, db: db, logger: defaultLogger, console: true, schedule: newSchedule(newMemoryJobStore(), defaultLogger)}
+	r.current.Store(r.newBot(oldConfig, nil))
+	if !runningFeatures(r.current.Load().(*Bot))["decide"] {
+		t.Fatal("decide is not running with the old config")
+	}
+
+	if err = r.Reload(newConfig); err != nil {
+		t.Fatalf("reloading failed: %v", err)
+	}
+
+	running := runningFeatures(r.current.Load().(*Bot))
+	if running["decide"] || !running["pingpong"] {
+		t.Errorf("reloading did not replace the features: %v", running)
+	}
+}
+
+func TestReloadKeepsOldConfigOnError(t *testing.T) {
+	old := &Bot{cfg: &Config{}, features: []Feature{&fakeFeature{name: "decide"}}}
+
+	r := &Runner{logger: defaultLogger}
+	r.current.Store(old)
+
+	// the apikey is required outside the console
+	if err := r.Reload(Config{}); err == nil {
+		t.Error("reloading a config without an apikey did not fail")
+	}
+
+	if r.current.Load().(*Bot) != old {
+		t.Error("a broken config replaced the old one")
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := Config{APIKey: "a", DatabaseURL: "b", Features: []byte(`{}`)}
+	new := old
+	new.Features = []byte(`{"decide": {}}`)
+	new.Priorities = map[string]int{"decide": 1}
+
+	if settings := restartRequired(old, new); len(settings) != 0 {
+		t.Errorf("reloadable changes required a restart: %v", settings)
+	}
+
+	new.APIKey = "c"
+	if settings := restartRequired(old, new); len(settings) != 1 || settings[0] != "apikey" {
+		t.Errorf("expected apikey to require a restart, got %v", settings)
+	}
+}
@@ -0,0 +1,100 @@
+package jbot
+
+import (
+	"context"
+	"sync"
+)
+
+// loggedCommands is the number of commands whose replies are remembered.
+const loggedCommands = 1000
+
+// replyLog remembers the replies of the bot to the last commands,
+// so that the replies can be edited when a command is edited.
+type replyLog struct {
+	mu      sync.Mutex
+	replies map[replyKey][]int // IDs of the replies to each command
+	order   []replyKey         // the keys of replies in the order they were added
+}
+
+// replyKey identifies a message by its chat and ID.
+type replyKey struct {
+	chatID    int64
+	messageID int
+}
+
+func newReplyLog() *replyLog {
+	return &replyLog{replies: make(map[replyKey][]int)}
+}
+
+// get returns the IDs of the replies to the message key.
+func (l *replyLog) get(key replyKey) []int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]int{}, l.replies[key]...)
+}
+
+// put sets the IDs of the replies to the message key.
+func (l *replyLog) put(key replyKey, replies []int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.replies[key]; !ok {
+		l.order = append(l.order, key)
+		if len(l.order) > loggedCommands {
+			delete(l.replies, l.order[0])
+			l.order = l.order[1:]
+		}
+	}
+	l.replies[key] = replies
+}
+
+// replyOrigin is the command that the replies sent during
+// its execution answer to.
+type replyOrigin struct {
+	key replyKey
+	log *replyLog // where the replies are remembered, may be nil
+
+	mu       sync.Mutex
+	previous []int // replies of an earlier version of the command that can be edited
+	sent     []int // replies sent or edited so far
+}
+
+type replyOriginKey struct{}
+
+// withReplyOrigin returns a copy of ctx in which Bot.Send records
+// the replies to the chat of origin, and edits the previous replies
+// of origin instead of sending new ones.
+func withReplyOrigin(ctx context.Context, origin *replyOrigin) context.Context {
+	return context.WithValue(ctx, replyOriginKey{}, origin)
+}
+
+// prepare makes r an edit of the next previous reply, if r goes
+// to the chat of the command and there is one left to edit.
+func (o *replyOrigin) prepare(r Reply) Reply {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if r.ChatID != o.key.chatID || r.EditMessageID != 0 || len(o.previous) == 0 {
+		return r
+	}
+	r.EditMessageID, o.previous = o.previous[0], o.previous[1:]
+	r.ReplyToMessageID = 0
+	return r
+}
+
+// record remembers the reply messageID if it went to the chat of the
+// command. Replies are sent in the background, so record may be called
+// after the command has been executed.
+func (o *replyOrigin) record(r Reply, messageID int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if r.ChatID != o.key.chatID || messageID == 0 {
+		return
+	}
+	o.sent = append(o.sent, messageID)
+	if o.log != nil {
+		o.log.put(o.key, append([]int{}, o.sent...))
+	}
+}
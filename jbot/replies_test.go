@@ -0,0 +1,54 @@
+package jbot
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEditedCommandEditsReplies(t *testing.T) {
+	m := &recordingMessenger{}
+	d := &decide{triggerWords: []string{"/decide"}}
+	features := []Feature{d}
+	bot := &Bot{messenger: m, features: features, commands: newCommandTable(features), replies: newReplyLog()}
+
+	dispatch(context.Background(), bot, textUpdate("/decide sauna sauna"))
+
+	edited := textUpdate("/decide lake lake")
+	edited.Kind = KindEditedMessage
+	dispatch(context.Background(), bot, edited)
+
+	if len(m.replies) != 2 {
+		t.Fatalf("expected 2 replies, got %+v", m.replies)
+	}
+	if m.replies[0].EditMessageID != 0 || m.replies[0].Text != "sauna" {
+		t.Errorf("unexpected first reply %+v", m.replies[0])
+	}
+	if m.replies[1].EditMessageID != 1 || m.replies[1].Text != "lake" {
+		t.Errorf("the edited command did not edit the first reply: %+v", m.replies[1])
+	}
+
+	// without the reply log, edited commands are ignored
+	bot.replies = nil
+	dispatch(context.Background(), bot, edited)
+	if len(m.replies) != 2 {
+		t.Errorf("an edited command ran although edited commands are off: %+v", m.replies[2:])
+	}
+}
+
+func TestReplyLogForgetsOldCommands(t *testing.T) {
+	l := newReplyLog()
+	for i := 0; i <= loggedCommands; i++ {
+		l.put(replyKey{1, i}, []int{i})
+	}
+	l.put(replyKey{1, loggedCommands}, []int{7})
+
+	if replies := l.get(replyKey{1, 0}); len(replies) != 0 {
+		t.Errorf("the oldest command was not forgotten: %v", replies)
+	}
+	if replies := l.get(replyKey{1, loggedCommands}); len(replies) != 1 || replies[0] != 7 {
+		t.Errorf("unexpected replies %v", replies)
+	}
+	if len(l.order) != loggedCommands {
+		t.Errorf("expected %v commands, got %v", loggedCommands, len(l.order))
+	}
+}
@@ -0,0 +1,364 @@
+package jbot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Options configure a Runner created with New.
+type Options struct {
+	// Config is the configuration of the bot.
+	Config Config
+
+	// DB is the database of the features. If nil, New opens
+	// Config.DatabaseURL and Close closes it again.
+	DB *sql.DB
+
+	// HTTPClient is used to talk to telegram and is available to
+	// features with Bot.HTTPClient. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Logger receives the log of the bot. Defaults to a logger
+	// that writes to stderr like the log package does. In the text
+	// format of the log, the prefix and the flags of Logger are used.
+	Logger *log.Logger
+
+	// If ConsoleIn is set, the bot chats in a terminal instead of telegram
+	// and Config.APIKey is not needed. Lines read from ConsoleIn are handled
+	// as messages to the bot and the replies are written to ConsoleOut,
+	// which defaults to stdout.
+	ConsoleIn  io.Reader
+	ConsoleOut io.Writer
+}
+
+// Runner runs the features of the bot for the updates it receives.
+// Unlike Start, a Runner does not read config.json or handle signals,
+// so it can be embedded in a larger program.
+type Runner struct {
+	cfg        Config
+	db         *sql.DB
+	ownDB      bool // true if New opened db
+	httpClient *http.Client
+	logger     *Logger
+	console    bool
+	username   string // username of the bot in telegram
+	messenger  messenger
+	menu       commandMenu // nil if the messenger has no command menu
+	outbox     *outbox     // queue of the replies to telegram, nil in the console
+	replies    *replyLog   // replies to the last commands, kept over reloads
+	stats      *runnerStats
+	rand       *random
+	schedule   *schedule // jobs of the features, kept over reloads
+	receive    func(ctx context.Context, submit func(Update) bool) error
+	features   []func() Feature // constructors of the features, the registered ones if nil
+
+	offsets     *offsetTracker // nil in the console, which has no offsets
+	offsetStore offsetStore
+
+	mu      sync.Mutex        // held while current is replaced
+	menuMu  sync.Mutex        // held while the command menu is set
+	menus   map[menuList]bool // command lists set by the last sync, guarded by menuMu
+	current atomic.Value      // the *Bot that handles new updates, replaced by Reload and supervise
+	work    workers           // background work of the features of current
+}
+
+// defaultLogger is used when no logger is given.
+var defaultLogger = newLogger(log.New(os.Stderr, "", log.LstdFlags))
+
+// New creates a Runner from opts and initialises the features.
+// Features that fail to initialise are logged and left out.
+func New(opts Options) (*Runner, error) {
+
+	r := &Runner{
+		cfg:        opts.Config,
+		db:         opts.DB,
+		httpClient: opts.HTTPClient,
+		console:    opts.ConsoleIn != nil,
+		replies:    newReplyLog(),
+		stats:      newRunnerStats(),
+		rand:       newRandom(),
+	}
+	if r.httpClient == nil {
+		r.httpClient = http.DefaultClient
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	r.logger = newLogger(opts.Logger)
+
+	if err := checkConfig(r.cfg, r.console); err != nil {
+		return nil, err
+	}
+	r.logger.configure(r.cfg.Log)
+	messages, err := loadCatalog(r.cfg.Locales)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.console {
+		out := opts.ConsoleOut
+		if out == nil {
+			out = os.Stdout
+		}
+		c := newConsole(opts.ConsoleIn, out)
+		r.messenger, r.receive = c, c.receive
+	} else {
+		botAPI, err := tgbotapi.NewBotAPIWithClient(r.cfg.APIKey, r.httpClient)
+		if err != nil {
+			return nil, err
+		}
+		r.logger.Info("telegram bot API authenticated", "username", botAPI.Self.UserName)
+		r.username = botAPI.Self.UserName
+
+		t := &telegram{botAPI}
+		r.outbox = newOutbox(t, r.cfg.RateLimit, r.logger, r.stats)
+		r.messenger, r.menu = r.outbox, t
+		r.receive = func(ctx context.Context, submit func(Update) bool) error {
+			if r.cfg.Webhook.Enabled {
+				return serveWebhook(ctx, botAPI, r.cfg.Webhook, submit, r.logger)
+			}
+			return pollUpdates(ctx, botAPI, r.offsets.offset(), submit, r.logger)
+		}
+	}
+
+	if r.db == nil {
+		db, err := sql.Open("postgres", r.cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		r.db, r.ownDB = db, true
+	}
+	if connected(r.db) {
+		r.logger.Info("connected to database")
+	} else {
+		r.logger.Warn("no database connection")
+	}
+
+	if !r.console {
+		r.offsetStore = newOffsetStore(r.db, r.cfg.OffsetFile)
+		r.offsets = newOffsetTracker(loadOffset(r.offsetStore, r.logger))
+	}
+
+	r.schedule = newSchedule(newJobStore(r.db, r.logger), r.logger)
+	r.logger.Info("loaded scheduled jobs", "store", r.schedule.store)
+
+	bot := r.newBot(r.cfg, messages)
+	r.logFeatures(bot)
+	r.current.Store(bot)
+	r.schedule.register(bot)
+	go r.syncCommands(bot)
+	r.stats.publish()
+	return r, nil
+}
+
+// checkConfig returns an error if a setting that the bot can't run
+// without is missing from cfg. The apikey is not needed in the console.
+func checkConfig(cfg Config, console bool) error {
+	if !console && cfg.APIKey == "" {
+		return errors.New("missing apikey")
+	}
+	if cfg.Webhook.Enabled && cfg.Webhook.URL == "" {
+		return errors.New("missing webhook url")
+	}
+	if cfg.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %v", err)
+		}
+	}
+	if err := checkLogConfig(cfg.Log); err != nil {
+		return err
+	}
+	if err := checkMiddleware(cfg.Middleware); err != nil {
+		return err
+	}
+	return checkCommandMenu(cfg.CommandMenu)
+}
+
+// Run receives updates and runs the features for them until ctx is done
+// or the updates end. Updates that are already being handled get the
+// configured shutdown timeout to finish before they are cancelled, and
+// a short grace period after that to return. Run must not be called
+// more than once.
+func (r *Runner) Run(ctx context.Context) error {
+
+	// executeCtx outlives ctx so that features can finish
+	// their work while the bot is shutting down
+	executeCtx, cancelExecute := context.WithCancel(context.Background())
+	defer cancelExecute()
+
+	handle := func(u Update) {
+		dispatch(executeCtx, r.current.Load().(*Bot), u)
+		if r.offsets != nil {
+			r.offsets.finish(u.ID)
+		}
+	}
+
+	d := newDispatcher(handle, r.cfg.Workers, r.cfg.QueueSize, r.cfg.ChatQueueSize, r.logger)
+
+	submit := d.submit
+	if r.offsets != nil {
+		saveCtx, stopSaving := context.WithCancel(context.Background())
+		go saveOffsets(saveCtx, r.offsets, r.offsetStore, r.offsets.offset(), r.logger)
+		defer func() {
+			stopSaving()
+			if err := r.offsetStore.save(r.offsets.offset()); err != nil {
+				r.logger.Error("failed to save update offset", "error", err)
+			}
+		}()
+
+		submit = func(u Update) bool {
+			if !r.offsets.begin(u.ID) {
+				r.logger.Info("ignoring duplicate update", "update", u.ID)
+				return false
+			}
+			if !d.submit(u) {
+				// a dropped update counts as handled
+				r.offsets.finish(u.ID)
+				return false
+			}
+			return true
+		}
+	}
+
+	r.mu.Lock()
+	r.work.start(ctx, r.current.Load().(*Bot))
+	r.mu.Unlock()
+
+	go r.supervise(ctx)
+	go r.schedule.run(ctx, func() *Bot { return r.current.Load().(*Bot) })
+
+	err := r.receive(ctx, submit)
+	if err != nil {
+		r.logger.Error("stopped receiving updates", "error", err)
+	}
+
+	r.logger.Info("shutting down")
+
+	timeout := defaultShutdownTimeout
+	if r.cfg.ShutdownTimeout > 0 {
+		timeout = time.Duration(r.cfg.ShutdownTimeout) * time.Second
+	}
+	if !d.closeWithTimeout(timeout) {
+		r.logger.Warn("features did not finish, cancelling them", "timeout", timeout)
+		cancelExecute()
+		if !d.wait(cancelGracePeriod) {
+			r.logger.Warn("cancelled features did not return", "timeout", cancelGracePeriod)
+		}
+	}
+	if !r.work.stop(timeout) {
+		r.logger.Warn("background work did not stop", "timeout", timeout)
+	}
+	if !r.schedule.wait(timeout) {
+		r.logger.Warn("scheduled jobs did not finish", "timeout", timeout)
+	}
+	if r.outbox != nil && !r.outbox.close(timeout) {
+		r.logger.Warn("queued replies were not sent", "timeout", timeout)
+	}
+	r.stats.logFeatureErrors(r.logger)
+
+	return err
+}
+
+// Close releases the resources of r. The database is closed
+// only if New opened it. Close must be called after Run returns.
+func (r *Runner) Close() error {
+	r.stats.unpublish()
+	if r.ownDB {
+		return r.db.Close()
+	}
+	return nil
+}
+
+// newBot creates a Bot and initialises its features from cfg.
+// Features that fail to initialise are left out and their
+// errors are kept in the failed map of the Bot.
+func (r *Runner) newBot(cfg Config, messages catalog) *Bot {
+
+	bot := &Bot{
+		messenger:  r.messenger,
+		username:   r.username,
+		database:   r.db,
+		httpClient: r.httpClient,
+		logger:     r.logger,
+		cfg:        &cfg,
+		catalog:    messages,
+		panics:     newPanicGuard(cfg.PanicLimit, r.logger),
+		stats:      r.stats,
+		rand:       r.rand,
+		schedule:   r.schedule,
+		failed:     make(map[string]error),
+		retry:      make(map[string]func() Feature),
+	}
+	if cfg.EditedCommands {
+		bot.replies = r.replies
+	}
+
+	for _, newFeature := range r.constructors() {
+		if feat, ok := r.initFeature(bot, newFeature); ok {
+			bot.features = append(bot.features, feat)
+		}
+	}
+	sortByPriority(bot.features, cfg.Priorities)
+	bot.commands = newCommandTable(bot.features)
+
+	return bot
+}
+
+// constructors returns the constructors of the features of r.
+func (r *Runner) constructors() []func() Feature {
+	if r.features != nil {
+		return r.features
+	}
+	return registeredFeatures()
+}
+
+// initFeature creates a feature with newFeature, initialises it for bot
+// and wraps it with its middlewares. If that fails, the error is kept in
+// the failed map of bot, and if the feature reports that it is unhealthy,
+// newFeature is kept for the supervisor to retry. Other errors, such as
+// a missing config or table, are not retried until the config is reloaded.
+func (r *Runner) initFeature(bot *Bot, newFeature func() Feature) (Feature, bool) {
+	feat := newFeature()
+	name := feat.String()
+	err := feat.Init(bot)
+	if err == nil {
+		var wrapped Feature
+		if wrapped, err = applyMiddleware(feat, bot.cfg.Middleware); err == nil {
+			return wrapped, true
+		}
+	}
+
+	bot.failed[name] = err
+	if checker, ok := healthChecker(feat); ok && checker.Healthy(bot) != nil {
+		bot.retry[name] = newFeature
+	}
+	return nil, false
+}
+
+// logFeatures logs which features of bot are running and why the others are not.
+func (r *Runner) logFeatures(bot *Bot) {
+	for _, feat := range bot.features {
+		r.logger.Info("running", "feature", feat.String())
+	}
+
+	failed := make([]string, 0, len(bot.failed))
+	for name := range bot.failed {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	for _, name := range failed {
+		r.logger.Warn("not running", "feature", name, "error", bot.failed[name])
+	}
+}
@@ -0,0 +1,54 @@
+package jbot
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRunnerRunsUntilInputEnds(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var out bytes.Buffer
+	r, err := New(Options{
+		Config:     Config{Features: []byte(`{"pingpong": [{"pings": ["ping"], "pongs": ["pong"]}]}`)},
+		DB:         db,
+		Logger:     log.New(ioutil.Discard, "", 0),
+		ConsoleIn:  strings.NewReader("ping\n"),
+		ConsoleOut: &out,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if err = r.Run(context.Background()); err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+	if err = r.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "bot: pong") {
+		t.Errorf("expected a pong in the console, got %q", out.String())
+	}
+}
+
+func TestNewChecksConfig(t *testing.T) {
+	if _, err := New(Options{Config: Config{DatabaseURL: "postgres://localhost"}}); err == nil {
+		t.Error("New accepted a config without an apikey")
+	}
+
+	cfg := Config{Webhook: WebhookConfig{Enabled: true}}
+	if _, err := New(Options{Config: cfg, ConsoleIn: strings.NewReader("")}); err == nil {
+		t.Error("New accepted an enabled webhook without a url")
+	}
+}
@@ -0,0 +1,369 @@
+package jbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// schedulePollInterval is the longest time the schedule sleeps before
+// it looks for due jobs again, so that it notices jobs that other
+// processes add to the database.
+const schedulePollInterval = time.Minute
+
+// Job is a job of a feature that runs at the times of a cron expression,
+// or once at a given time. Jobs are kept in the database, so they survive
+// restarts, and each run of a job happens at most once, even if several
+// processes share the database. A run that was due while the bot was
+// not running happens once when the bot starts again.
+type Job struct {
+	Name     string    // unique name of the job, e.g. "reminder.1000.17", best prefixed with the name of the feature
+	Cron     string    // cron expression of a repeating job, e.g. "0 9 * * mon-fri" for 09:00 on weekdays
+	At       time.Time // time of a job that runs once, used if Cron is empty
+	Timezone string    // time zone of Cron, e.g. "Europe/Helsinki", default the "timezone" of the config
+	ChatID   int64     // chat of the job, 0 if none. Runs are skipped while the feature is disabled in the chat.
+	Data     string    // anything the feature needs to run the job
+
+	Feature string    // feature that owns the job, set by the bot
+	Next    time.Time // time of the next run, set by the bot. In RunJob, the time the run was due.
+
+	declared bool // true if the job comes from the Jobs of a Scheduler
+}
+
+// Scheduler can be implemented by features that have jobs that always
+// run, such as a daily update. Jobs returns those jobs. They are added
+// when the feature starts running and updated when they change, for
+// example when the config is reloaded. Jobs that the feature no longer
+// returns are removed, and so are the jobs of a feature that stops
+// running, unless it is only waiting to be started again.
+type Scheduler interface {
+	Jobs() []Job
+}
+
+// JobRunner must be implemented by features that have jobs, either
+// from Scheduler or from Bot.Schedule. RunJob is called in its own
+// goroutine when a job of the feature is due. It should return when
+// ctx is done, which happens when the bot stops.
+type JobRunner interface {
+	RunJob(ctx context.Context, bot *Bot, job Job) error
+}
+
+// schedulerOf returns the Scheduler of feat, looking through middlewares.
+func schedulerOf(feat Feature) (Scheduler, bool) {
+	for _, f := range featureChain(feat) {
+		if scheduler, ok := f.(Scheduler); ok {
+			return scheduler, true
+		}
+	}
+	return nil, false
+}
+
+// jobRunnerOf returns the JobRunner of feat, looking through middlewares.
+func jobRunnerOf(feat Feature) (JobRunner, bool) {
+	for _, f := range featureChain(feat) {
+		if runner, ok := f.(JobRunner); ok {
+			return runner, true
+		}
+	}
+	return nil, false
+}
+
+// schedule runs the jobs of the features of the current Bot.
+type schedule struct {
+	store  jobStore
+	logger *Logger
+	wake   chan struct{} // wakes up run when a job is added
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	location *time.Location // time zone of the jobs that have none
+}
+
+func newSchedule(store jobStore, logger *Logger) *schedule {
+	return &schedule{
+		store:    store,
+		logger:   logger,
+		wake:     make(chan struct{}, 1),
+		location: time.Local,
+	}
+}
+
+// locationOf returns the time zone of job.
+func (s *schedule) locationOf(job Job) (*time.Location, error) {
+	if job.Timezone == "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.location, nil
+	}
+	return time.LoadLocation(job.Timezone)
+}
+
+// nextRun returns the first run of job after now.
+// It returns the zero time if job runs only once.
+func (s *schedule) nextRun(job Job, now time.Time) (time.Time, error) {
+	if job.Cron == "" {
+		return time.Time{}, nil
+	}
+
+	cron, err := parseCron(job.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	location, err := s.locationOf(job)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cron.next(now.In(location)), nil
+}
+
+// add adds job to the jobs of feature and wakes up run.
+// An existing job with the same name is replaced.
+func (s *schedule) add(ctx context.Context, feature string, job Job) error {
+	if job.Name == "" {
+		return errors.New("job has no name")
+	}
+	if job.Cron == "" && job.At.IsZero() {
+		return fmt.Errorf("job %v has neither a cron expression nor a time", job.Name)
+	}
+
+	job.Feature = feature
+	if job.Cron == "" {
+		job.Next = job.At
+	} else {
+		var err error
+		if job.Next, err = s.nextRun(job, time.Now()); err != nil {
+			return fmt.Errorf("job %v: %v", job.Name, err)
+		}
+	}
+
+	if err := s.store.put(ctx, job); err != nil {
+		return err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// register adds the jobs of the Schedulers of bot that are new or have
+// changed and removes the ones that are no longer declared. It takes the
+// default time zone from the config of bot.
+func (s *schedule) register(bot *Bot) {
+	if bot.cfg != nil && bot.cfg.Timezone != "" {
+		location, err := time.LoadLocation(bot.cfg.Timezone)
+		if err == nil {
+			s.mu.Lock()
+			s.location = location
+			s.mu.Unlock()
+		}
+	}
+
+	ctx := context.Background()
+	declared := make(map[string]bool)
+	for _, feat := range bot.features {
+		scheduler, ok := schedulerOf(feat)
+		if !ok {
+			continue
+		}
+
+		for _, job := range scheduler.Jobs() {
+			job.declared = true
+			declared[job.Name] = true
+
+			stored, ok, err := s.store.get(ctx, job.Name)
+			if err != nil {
+				s.logger.Error("failed to load job", "feature", feat.String(), "job", job.Name, "error", err)
+				continue
+			}
+			if ok && sameJob(stored, job) && stored.Feature == feat.String() && stored.declared {
+				continue
+			}
+			if err = s.add(ctx, feat.String(), job); err != nil {
+				s.logger.Error("failed to schedule job", "feature", feat.String(), "job", job.Name, "error", err)
+			}
+		}
+	}
+
+	stored, err := s.store.declared(ctx)
+	if err != nil {
+		s.logger.Error("failed to load scheduled jobs", "error", err)
+		return
+	}
+	for _, job := range stored {
+		// the jobs of features that wait to be started again are kept
+		if _, retried := bot.retry[job.Feature]; declared[job.Name] || retried {
+			continue
+		}
+		if err = s.store.remove(ctx, job.Feature, job.Name); err != nil {
+			s.logger.Error("failed to remove job", "feature", job.Feature, "job", job.Name, "error", err)
+			continue
+		}
+		s.logger.Info("removed job that is no longer declared", "feature", job.Feature, "job", job.Name)
+	}
+}
+
+// sameJob returns true if a and b are defined the same way.
+func sameJob(a, b Job) bool {
+	return a.Name == b.Name && a.Cron == b.Cron && a.Timezone == b.Timezone &&
+		a.ChatID == b.ChatID && a.Data == b.Data && (a.Cron != "" || a.At.Equal(b.At))
+}
+
+// run runs the jobs of the Bot that current returns when they are due,
+// until ctx is done.
+func (s *schedule) run(ctx context.Context, current func() *Bot) {
+	for {
+		now := time.Now()
+		s.runDue(ctx, current(), now)
+
+		wait := schedulePollInterval
+		next, ok, err := s.store.earliest(ctx, now)
+		if err != nil {
+			s.logger.Error("failed to load scheduled jobs", "error", err)
+		} else if ok && next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// runDue starts the jobs that are due at now. A job runs only if it
+// can be claimed, so it runs once even if it is due in several processes.
+// Jobs of features that are not running stay due until they run again.
+func (s *schedule) runDue(ctx context.Context, bot *Bot, now time.Time) {
+	jobs, err := s.store.due(ctx, now)
+	if err != nil {
+		s.logger.Error("failed to load scheduled jobs", "error", err)
+		return
+	}
+
+	for _, job := range jobs {
+		feat, ok := runningFeature(bot, job.Feature)
+		if !ok || bot.panics.isDisabled(job.Feature) {
+			continue
+		}
+		runner, ok := jobRunnerOf(feat)
+		if !ok {
+			continue
+		}
+
+		next, err := s.nextRun(job, now)
+		if err != nil {
+			s.logger.Error("job can't run", "feature", job.Feature, "job", job.Name, "error", err)
+			continue
+		}
+		claimed, err := s.store.claim(ctx, job, next)
+		if err != nil {
+			s.logger.Error("failed to claim job", "feature", job.Feature, "job", job.Name, "error", err)
+			continue
+		}
+		if !claimed || (job.ChatID != 0 && !featureRunsInChat(ctx, bot, job.ChatID, job.Feature)) {
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.execute(ctx, bot, runner, job)
+	}
+}
+
+// execute runs job and logs and counts its errors and panics.
+// The log lines of the job carry its feature and name.
+func (s *schedule) execute(ctx context.Context, bot *Bot, runner JobRunner, job Job) {
+	defer s.wg.Done()
+
+	logger := bot.Log(ctx).With("feature", job.Feature, "job", job.Name)
+	start := time.Now()
+	err := callRunJob(withLogger(ctx, logger), bot, runner, job)
+	latency := time.Since(start)
+	bot.panics.record(job.Feature, err)
+	if err == nil {
+		logger.Debug("job ran", "due", job.Next, "latency", latency)
+		return
+	}
+
+	errors := bot.stats.featureFailed(job.Feature)
+	if p, ok := err.(*panicError); ok {
+		bot.stats.featurePanicked(job.Feature)
+		logger.Error("job panicked", "due", job.Next, "latency", latency, "errors", errors, "error", err, "stack", p.stack)
+		return
+	}
+	logger.Error("job failed", "due", job.Next, "latency", latency, "errors", errors, "error", err)
+}
+
+// callRunJob calls runner.RunJob. If it panics, the panic is returned as an error.
+func callRunJob(ctx context.Context, bot *Bot, runner JobRunner, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{r, debug.Stack()}
+		}
+	}()
+	return runner.RunJob(ctx, bot, job)
+}
+
+// wait waits at most timeout for the running jobs to return.
+// It returns false if they did not return in time.
+func (s *schedule) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// runningFeature returns the running feature of bot called name.
+func runningFeature(bot *Bot, name string) (Feature, bool) {
+	for _, feat := range bot.features {
+		if feat.String() == name {
+			return feat, true
+		}
+	}
+	return nil, false
+}
+
+// Schedule adds job to the jobs of feat, which must implement JobRunner.
+// A job with the same name is replaced. Use it for jobs that users
+// create, such as reminders. Jobs that always run are better returned
+// from Scheduler.
+func (bot *Bot) Schedule(ctx context.Context, feat Feature, job Job) error {
+	if bot.schedule == nil {
+		return errors.New("no schedule")
+	}
+	return bot.schedule.add(ctx, feat.String(), job)
+}
+
+// Unschedule removes the job of feat called name. Removing
+// a job that doesn't exist is not an error.
+func (bot *Bot) Unschedule(ctx context.Context, feat Feature, name string) error {
+	if bot.schedule == nil {
+		return errors.New("no schedule")
+	}
+	return bot.schedule.store.remove(ctx, feat.String(), name)
+}
+
+// ScheduledJobs returns the jobs of feat in the order they run next.
+func (bot *Bot) ScheduledJobs(ctx context.Context, feat Feature) ([]Job, error) {
+	if bot.schedule == nil {
+		return nil, errors.New("no schedule")
+	}
+	return bot.schedule.store.list(ctx, feat.String())
+}
@@ -0,0 +1,260 @@
+package jbot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// jobFeature is a feature that records the jobs it runs.
+type jobFeature struct {
+	fakeFeature
+	jobs []Job // returned from Jobs
+	err  error
+
+	mu  sync.Mutex
+	ran []Job
+}
+
+func (f *jobFeature) Jobs() []Job {
+	return f.jobs
+}
+
+func (f *jobFeature) RunJob(ctx context.Context, bot *Bot, job Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.ran = append(f.ran, job)
+	if job.Data == "panic" {
+		panic("job exploded")
+	}
+	return f.err
+}
+
+func (f *jobFeature) runJobs() []Job {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Job(nil), f.ran...)
+}
+
+func TestScheduleRunsDueJobsOnce(t *testing.T) {
+	feat := &jobFeature{fakeFeature: fakeFeature{name: "jobs_test"}}
+	store := newMemoryJobStore()
+	bot := &Bot{features: []Feature{feat}, schedule: newSchedule(store, defaultLogger)}
+	// another process shares the jobs
+	other := newSchedule(store, defaultLogger)
+	ctx := context.Background()
+
+	now := time.Now()
+	if err := bot.Schedule(ctx, feat, Job{Name: "once", At: now.Add(-time.Minute), Data: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.Schedule(ctx, feat, Job{Name: "later", At: now.Add(72 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.Schedule(ctx, feat, Job{Name: "daily", Cron: "0 9 * * *", Timezone: "UTC"}); err != nil {
+		t.Fatal(err)
+	}
+
+	bot.schedule.runDue(ctx, bot, now)
+	other.runDue(ctx, bot, now)
+	bot.schedule.runDue(ctx, bot, now)
+	bot.schedule.wait(time.Second)
+	other.wait(time.Second)
+
+	ran := feat.runJobs()
+	if len(ran) != 1 || ran[0].Name != "once" || ran[0].Data != "a" || ran[0].Feature != "jobs_test" {
+		t.Fatalf("expected the due job to run once, got %+v", ran)
+	}
+
+	jobs, err := bot.ScheduledJobs(ctx, feat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].Name != "daily" || jobs[1].Name != "later" {
+		t.Errorf("expected the jobs that run once to be removed after running, got %+v", jobs)
+	}
+
+	// the daily job runs at its time and is moved to the next day
+	due := jobs[0].Next
+	bot.schedule.runDue(ctx, bot, due)
+	bot.schedule.wait(time.Second)
+	if ran := feat.runJobs(); len(ran) != 2 || ran[1].Name != "daily" || !ran[1].Next.Equal(due) {
+		t.Fatalf("expected the daily job to run, got %+v", ran)
+	}
+	if job, _, _ := store.get(ctx, "daily"); !job.Next.Equal(due.Add(24 * time.Hour)) {
+		t.Errorf("expected the daily job to run next at %v, got %v", due.Add(24*time.Hour), job.Next)
+	}
+
+	if err := bot.Unschedule(ctx, feat, "later"); err != nil {
+		t.Fatal(err)
+	}
+	if jobs, _ := bot.ScheduledJobs(ctx, feat); len(jobs) != 1 {
+		t.Errorf("expected one job after removing one, got %+v", jobs)
+	}
+}
+
+func TestScheduleRejectsInvalidJobs(t *testing.T) {
+	feat := &jobFeature{fakeFeature: fakeFeature{name: "jobs_test"}}
+	bot := &Bot{schedule: newSchedule(newMemoryJobStore(), defaultLogger)}
+	ctx := context.Background()
+
+	for _, job := range []Job{
+		{Cron: "0 9 * * *"},
+		{Name: "nothing"},
+		{Name: "bad cron", Cron: "0 9 * *"},
+		{Name: "bad time zone", Cron: "0 9 * * *", Timezone: "Moon/Base"},
+	} {
+		if err := bot.Schedule(ctx, feat, job); err == nil {
+			t.Errorf("job %+v was accepted", job)
+		}
+	}
+
+	if err := (&Bot{}).Schedule(ctx, feat, Job{Name: "once", At: time.Now()}); err == nil {
+		t.Error("a job was scheduled without a schedule")
+	}
+}
+
+func TestScheduleSkipsJobsOfFeaturesThatDontRun(t *testing.T) {
+	feat := &jobFeature{fakeFeature: fakeFeature{name: "jobs_test"}}
+	store := newMemoryJobStore()
+	bot := &Bot{schedule: newSchedule(store, defaultLogger)}
+	ctx := context.Background()
+
+	now := time.Now()
+	if err := bot.Schedule(ctx, feat, Job{Name: "once", At: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the job stays due until the feature runs
+	bot.schedule.runDue(ctx, bot, now)
+	if len(feat.runJobs()) != 0 {
+		t.Fatal("a job of a feature that doesn't run ran")
+	}
+
+	running := &Bot{features: []Feature{feat}, schedule: bot.schedule}
+	running.schedule.runDue(ctx, running, now)
+	running.schedule.wait(time.Second)
+	if len(feat.runJobs()) != 1 {
+		t.Fatal("the job did not run when the feature started running")
+	}
+}
+
+func TestScheduleCountsFailedJobs(t *testing.T) {
+	feat := &jobFeature{fakeFeature: fakeFeature{name: "failing_jobs_test"}, err: errors.New("boom")}
+	bot := &Bot{features: []Feature{feat}, schedule: newSchedule(newMemoryJobStore(), defaultLogger), stats: newRunnerStats()}
+	ctx := context.Background()
+
+	now := time.Now()
+	for _, job := range []Job{{Name: "fails", At: now}, {Name: "panics", At: now, Data: "panic"}} {
+		if err := bot.Schedule(ctx, feat, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bot.schedule.runDue(ctx, bot, now)
+	if !bot.schedule.wait(time.Second) {
+		t.Fatal("jobs did not finish")
+	}
+
+	if errs := bot.stats.featureErrors.Get("failing_jobs_test"); errs == nil || errs.String() != "2" {
+		t.Errorf("expected 2 errors, got %v", errs)
+	}
+	if panics := bot.stats.featurePanics.Get("failing_jobs_test"); panics == nil || panics.String() != "1" {
+		t.Errorf("expected 1 panic, got %v", panics)
+	}
+}
+
+func TestScheduleRegistersJobsOfFeatures(t *testing.T) {
+	feat := &jobFeature{
+		fakeFeature: fakeFeature{name: "jobs_test"},
+		jobs:        []Job{{Name: "daily", Cron: "0 9 * * *"}},
+	}
+	store := newMemoryJobStore()
+	s := newSchedule(store, defaultLogger)
+	ctx := context.Background()
+
+	s.register(&Bot{features: []Feature{feat}, cfg: &Config{Timezone: "UTC"}})
+	job, ok, _ := store.get(ctx, "daily")
+	if !ok || job.Feature != "jobs_test" || job.Next.Hour() != 9 || job.Next.Location() != time.UTC {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	// an unchanged job keeps its next run, even if it was missed
+	missed := time.Now().Add(-time.Hour)
+	job.Next = missed
+	store.put(ctx, job)
+	s.register(&Bot{features: []Feature{feat}})
+	if job, _, _ = store.get(ctx, "daily"); !job.Next.Equal(missed) {
+		t.Errorf("the next run of an unchanged job moved to %v", job.Next)
+	}
+
+	// a changed job is rescheduled
+	feat.jobs = []Job{{Name: "daily", Cron: "30 10 * * *"}}
+	s.register(&Bot{features: []Feature{feat}})
+	if job, _, _ = store.get(ctx, "daily"); job.Cron != "30 10 * * *" || job.Next.Hour() != 10 || job.Next.Minute() != 30 {
+		t.Errorf("the changed job was not rescheduled: %+v", job)
+	}
+}
+
+func TestScheduleRemovesJobsNoLongerDeclared(t *testing.T) {
+	feat := &jobFeature{
+		fakeFeature: fakeFeature{name: "jobs_test"},
+		jobs:        []Job{{Name: "daily", Cron: "0 9 * * *"}, {Name: "weekly", Cron: "0 9 * * mon"}},
+	}
+	retried := &jobFeature{
+		fakeFeature: fakeFeature{name: "retried_test"},
+		jobs:        []Job{{Name: "hourly", Cron: "0 * * * *"}},
+	}
+	store := newMemoryJobStore()
+	s := newSchedule(store, defaultLogger)
+	ctx := context.Background()
+
+	bot := &Bot{features: []Feature{feat, retried}}
+	s.register(bot)
+	if err := s.add(ctx, "jobs_test", Job{Name: "reminder", At: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// weekly is no longer declared and retried_test waits to be started again
+	feat.jobs = feat.jobs[:1]
+	s.register(&Bot{features: []Feature{feat}, retry: map[string]func() Feature{"retried_test": nil}})
+	for name, expected := range map[string]bool{"daily": true, "weekly": false, "hourly": true, "reminder": true} {
+		if _, ok, _ := store.get(ctx, name); ok != expected {
+			t.Errorf("expected the job %v to be kept: %v", name, expected)
+		}
+	}
+
+	// the jobs of a feature that stopped running are removed
+	s.register(&Bot{})
+	for name, expected := range map[string]bool{"daily": false, "hourly": false, "reminder": true} {
+		if _, ok, _ := store.get(ctx, name); ok != expected {
+			t.Errorf("expected the job %v to be kept: %v", name, expected)
+		}
+	}
+}
+
+func TestScheduleRunsAddedJobs(t *testing.T) {
+	feat := &jobFeature{fakeFeature: fakeFeature{name: "jobs_test"}}
+	bot := &Bot{features: []Feature{feat}, schedule: newSchedule(newMemoryJobStore(), defaultLogger)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bot.schedule.run(ctx, func() *Bot { return bot })
+
+	// the schedule wakes up for the new job instead of sleeping for a minute
+	if err := bot.Schedule(ctx, feat, Job{Name: "soon", At: time.Now().Add(50 * time.Millisecond)}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(feat.runJobs()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the job did not run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,133 @@
+package jbot
+
+import (
+	"expvar"
+	"sync"
+)
+
+// runnerStats are the counters of a Runner, so that two Runners in one
+// process don't count for each other. The expvars of the process add up
+// the counters of the Runners that have not been closed. A nil
+// runnerStats, as in a Bot that was not made by a Runner, counts nothing.
+type runnerStats struct {
+	featureErrors   expvar.Map // errors returned by each feature
+	featurePanics   expvar.Map // panics of each feature
+	droppedMessages expvar.Int // messages that could not be sent
+	horoscope       horoscopeUpdateLog
+}
+
+func newRunnerStats() *runnerStats {
+	s := new(runnerStats)
+	s.featureErrors.Init()
+	s.featurePanics.Init()
+	return s
+}
+
+// featureFailed counts an error of the feature called name
+// and returns the number of its errors so far.
+func (s *runnerStats) featureFailed(name string) int64 {
+	if s == nil {
+		return 0
+	}
+	s.featureErrors.Add(name, 1)
+	return s.featureErrors.Get(name).(*expvar.Int).Value()
+}
+
+// featurePanicked counts a panic of the feature called name.
+func (s *runnerStats) featurePanicked(name string) {
+	if s != nil {
+		s.featurePanics.Add(name, 1)
+	}
+}
+
+// messageDropped counts a message that could not be sent.
+func (s *runnerStats) messageDropped() {
+	if s != nil {
+		s.droppedMessages.Add(1)
+	}
+}
+
+// horoscopeUpdates returns the status of the updates of the horoscopes.
+func (s *runnerStats) horoscopeUpdates() *horoscopeUpdateLog {
+	if s == nil {
+		return new(horoscopeUpdateLog)
+	}
+	return &s.horoscope
+}
+
+// logFeatureErrors logs the number of errors of every feature that has failed.
+func (s *runnerStats) logFeatureErrors(logger *Logger) {
+	if s == nil {
+		return
+	}
+	s.featureErrors.Do(func(kv expvar.KeyValue) {
+		logger.Info("feature errors", "feature", kv.Key, "errors", kv.Value)
+	})
+}
+
+var (
+	publishedMu    sync.Mutex
+	publishedStats = make(map[*runnerStats]bool) // stats of the Runners that have not been closed
+)
+
+// publish adds s to the expvars of the process.
+func (s *runnerStats) publish() {
+	publishedMu.Lock()
+	defer publishedMu.Unlock()
+	publishedStats[s] = true
+}
+
+// unpublish removes s from the expvars of the process.
+func (s *runnerStats) unpublish() {
+	publishedMu.Lock()
+	defer publishedMu.Unlock()
+	delete(publishedStats, s)
+}
+
+func init() {
+	expvar.Publish("jbot_feature_errors", expvar.Func(func() interface{} {
+		return sumPublished(func(s *runnerStats) *expvar.Map { return &s.featureErrors })
+	}))
+	expvar.Publish("jbot_feature_panics", expvar.Func(func() interface{} {
+		return sumPublished(func(s *runnerStats) *expvar.Map { return &s.featurePanics })
+	}))
+	expvar.Publish("jbot_dropped_messages", expvar.Func(func() interface{} {
+		publishedMu.Lock()
+		defer publishedMu.Unlock()
+
+		var dropped int64
+		for s := range publishedStats {
+			dropped += s.droppedMessages.Value()
+		}
+		return dropped
+	}))
+	expvar.Publish("jbot_horoscope_updates", expvar.Func(func() interface{} {
+		publishedMu.Lock()
+		defer publishedMu.Unlock()
+
+		// the status of the Runner that tried last
+		var latest horoscopeUpdateStatus
+		for s := range publishedStats {
+			if status := s.horoscope.snapshot(); status.LastAttempt.After(latest.LastAttempt) {
+				latest = status
+			}
+		}
+		return latest
+	}))
+}
+
+// sumPublished adds up the counts in the map of each published runnerStats.
+func sumPublished(countsOf func(s *runnerStats) *expvar.Map) map[string]int64 {
+	publishedMu.Lock()
+	defer publishedMu.Unlock()
+
+	sums := make(map[string]int64)
+	for s := range publishedStats {
+		countsOf(s).Do(func(kv expvar.KeyValue) {
+			if count, ok := kv.Value.(*expvar.Int); ok {
+				sums[kv.Key] += count.Value()
+			}
+		})
+	}
+	return sums
+}
@@ -0,0 +1,47 @@
+package jbot
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"testing"
+	"time"
+)
+
+func TestRunnerStatsAreSeparate(t *testing.T) {
+	a, b := newRunnerStats(), newRunnerStats()
+	a.publish()
+	b.publish()
+	defer a.unpublish()
+	defer b.unpublish()
+
+	a.featureFailed("stats_test")
+	if errs := b.featureFailed("stats_test"); errs != 1 {
+		t.Errorf("the errors of another runner were counted: %v", errs)
+	}
+	a.messageDropped()
+	a.horoscope.record(time.Now(), errors.New("API down"))
+	if b.horoscope.snapshot().Failures != 0 {
+		t.Error("the horoscope update of another runner was recorded")
+	}
+
+	// the expvars add up the runners
+	var errs map[string]int64
+	if err := json.Unmarshal([]byte(expvar.Get("jbot_feature_errors").String()), &errs); err != nil {
+		t.Fatal(err)
+	}
+	if errs["stats_test"] != 2 {
+		t.Errorf("expected 2 published errors, got %v", errs["stats_test"])
+	}
+	if dropped := expvar.Get("jbot_dropped_messages").String(); dropped != "1" {
+		t.Errorf("expected 1 published dropped message, got %v", dropped)
+	}
+
+	b.unpublish()
+	if err := json.Unmarshal([]byte(expvar.Get("jbot_feature_errors").String()), &errs); err != nil {
+		t.Fatal(err)
+	}
+	if errs["stats_test"] != 1 {
+		t.Errorf("the errors of a closed runner were published: %v", errs["stats_test"])
+	}
+}
@@ -0,0 +1,169 @@
+package jbot
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultRetryInterval = 10 * time.Second
+	maxRetryInterval     = 5 * time.Minute
+)
+
+// HealthChecker can be implemented by features that depend on something
+// that may go away while the bot is running, such as the database.
+// Healthy returns an error if the feature can't work at the moment.
+// The bot then stops running the feature until its Init succeeds again.
+// Init is retried only while Healthy returns an error, so a feature
+// that fails Init while it is healthy waits for the next config reload.
+type HealthChecker interface {
+	Healthy(*Bot) error
+}
+
+// healthChecker returns the HealthChecker of feat, looking through middlewares.
+func healthChecker(feat Feature) (HealthChecker, bool) {
+	for _, f := range featureChain(feat) {
+		if checker, ok := f.(HealthChecker); ok {
+			return checker, true
+		}
+	}
+	return nil, false
+}
+
+// supervise keeps the features running until ctx is done. Every retry
+// interval it checks the health of the running features and tries to
+// initialise the ones that are not running. While some features keep
+// failing, the interval doubles up to maxRetryInterval.
+func (r *Runner) supervise(ctx context.Context) {
+	interval := defaultRetryInterval
+	if r.cfg.RetryInterval > 0 {
+		interval = time.Duration(r.cfg.RetryInterval) * time.Second
+	}
+
+	wait := interval
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		if r.checkFeatures() {
+			wait = interval
+		} else if wait *= 2; wait > maxRetryInterval {
+			wait = maxRetryInterval
+		}
+	}
+}
+
+// checkFeatures replaces the current Bot if a running feature has become
+// unhealthy or a failed feature that was unhealthy initialises now. Only
+// the failed features that are healthy again are initialised, and the
+// running features are kept as they are. It returns true if every
+// feature that can be retried is running and healthy.
+func (r *Runner) checkFeatures() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bot := r.current.Load().(*Bot)
+
+	next := *bot
+	next.features = nil
+	next.failed = make(map[string]error)
+	next.retry = make(map[string]func() Feature)
+	for name, err := range bot.failed {
+		next.failed[name] = err
+	}
+	for name, newFeature := range bot.retry {
+		next.retry[name] = newFeature
+	}
+
+	changed := false
+	for _, feat := range bot.features {
+		if checker, ok := healthChecker(feat); ok {
+			if err := checker.Healthy(bot); err != nil {
+				r.logger.Warn("feature is offline", "feature", feat.String(), "error", err)
+				next.failed[feat.String()] = err
+				if newFeature := r.constructorOf(feat.String()); newFeature != nil {
+					next.retry[feat.String()] = newFeature
+				}
+				changed = true
+				continue
+			}
+		}
+		next.features = append(next.features, feat)
+	}
+	if !changed && len(bot.retry) == 0 {
+		return true
+	}
+
+	// the per chat state of features that went offline goes with them
+	if _, ok := runningFeature(&next, "toggles"); !ok {
+		next.toggles = nil
+	}
+	if _, ok := runningFeature(&next, "language"); !ok {
+		next.languages = nil
+	}
+
+	for name, newFeature := range bot.retry {
+		if !probeFeature(&next, newFeature) {
+			continue
+		}
+		delete(next.failed, name)
+		delete(next.retry, name)
+		if feat, ok := r.initFeature(&next, newFeature); ok {
+			r.logger.Info("feature is back online", "feature", name)
+			next.features = append(next.features, feat)
+			changed = true
+		} else {
+			r.logger.Warn("not running", "feature", name, "error", next.failed[name])
+		}
+	}
+
+	if changed {
+		next.features = inRegistrationOrder(next.features, r.constructors())
+		sortByPriority(next.features, next.cfg.Priorities)
+		next.commands = newCommandTable(next.features)
+		r.current.Store(&next)
+		r.work.switchTo(&next)
+		r.schedule.register(&next)
+		go r.syncCommands(&next)
+	}
+	return len(next.retry) == 0
+}
+
+// probeFeature returns true if a feature made by newFeature reports that
+// it is healthy for bot, so that initialising it is worth a try.
+func probeFeature(bot *Bot, newFeature func() Feature) bool {
+	checker, ok := healthChecker(newFeature())
+	return !ok || checker.Healthy(bot) == nil
+}
+
+// constructorOf returns the constructor of the feature called name.
+func (r *Runner) constructorOf(name string) func() Feature {
+	for _, newFeature := range r.constructors() {
+		if newFeature().String() == name {
+			return newFeature
+		}
+	}
+	return nil
+}
+
+// inRegistrationOrder returns features in the order of their constructors,
+// so that features with equal priorities keep their order after a retry.
+func inRegistrationOrder(features []Feature, constructors []func() Feature) []Feature {
+	byName := make(map[string]Feature, len(features))
+	for _, feat := range features {
+		byName[feat.String()] = feat
+	}
+
+	ordered := make([]Feature, 0, len(features))
+	for _, newFeature := range constructors {
+		if feat, ok := byName[newFeature().String()]; ok {
+			ordered = append(ordered, feat)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,106 @@
+package jbot
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// flakyReady decides whether flakyFeature can initialise and is healthy.
+var flakyReady bool
+
+// flakyFeature depends on something that comes and goes.
+type flakyFeature struct {
+	fakeFeature
+}
+
+func (f *flakyFeature) Init(bot *Bot) error {
+	return f.Healthy(bot)
+}
+
+func (f *flakyFeature) Healthy(bot *Bot) error {
+	if !flakyReady {
+		return errors.New("not ready")
+	}
+	return nil
+}
+
+func TestCheckFeaturesRestartsFlakyFeature(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	flakyReady = false
+	defer func() { flakyReady = false }()
+
+	r := &Runner{messenger: &recordingMessenger{}, db: db, logger: newLogger(log.New(ioutil.Discard, "", 0)), console: true,
+		schedule: newSchedule(newMemoryJobStore(), defaultLogger),
+		features: []func() Feature{func() Feature { return &flakyFeature{fakeFeature{name: "flaky_test"}} }}}
+	r.current.Store(r.newBot(Config{Middleware: map[string][]string{"*": {"timing"}}}, nil))
+
+	if runningFeatures(r.current.Load().(*Bot))["flaky_test"] {
+		t.Fatal("flaky feature is running before it is ready")
+	}
+
+	r.checkFeatures()
+	if runningFeatures(r.current.Load().(*Bot))["flaky_test"] {
+		t.Fatal("flaky feature started before it is ready")
+	}
+
+	flakyReady = true
+	r.checkFeatures()
+	if !runningFeatures(r.current.Load().(*Bot))["flaky_test"] {
+		t.Fatal("flaky feature did not come back online")
+	}
+
+	flakyReady = false
+	r.checkFeatures()
+	if runningFeatures(r.current.Load().(*Bot))["flaky_test"] {
+		t.Error("unhealthy flaky feature kept running")
+	}
+}
+
+// brokenFeature fails to initialise for a reason that retrying doesn't fix.
+type brokenFeature struct {
+	fakeFeature
+	inits *int
+}
+
+func (f *brokenFeature) Init(bot *Bot) error {
+	*f.inits++
+	return errors.New("missing configs")
+}
+
+func (f *brokenFeature) Healthy(bot *Bot) error {
+	return nil
+}
+
+func TestCheckFeaturesDoesNotRetryBrokenFeature(t *testing.T) {
+	inits, running := 0, 0
+	r := &Runner{messenger: &recordingMessenger{}, logger: newLogger(log.New(ioutil.Discard, "", 0)), console: true,
+		schedule: newSchedule(newMemoryJobStore(), defaultLogger),
+		features: []func() Feature{
+			func() Feature { return &brokenFeature{fakeFeature: fakeFeature{name: "broken_test"}, inits: &inits} },
+			func() Feature { running++; return &fakeFeature{name: "running_test"} },
+		}}
+	r.current.Store(r.newBot(Config{}, nil))
+	bot := r.current.Load().(*Bot)
+	if _, failed := bot.failed["broken_test"]; !failed || inits != 1 {
+		t.Fatalf("expected the broken feature to fail once, failed: %v", bot.failed)
+	}
+
+	created := running
+	for i := 0; i < 3; i++ {
+		if !r.checkFeatures() {
+			t.Error("a broken feature counts as retried")
+		}
+	}
+	if inits != 1 || running != created || r.current.Load().(*Bot) != bot {
+		t.Errorf("the features were initialised again: %v inits of the broken feature, %v of the running one", inits, running-created)
+	}
+}
@@ -0,0 +1,262 @@
+package jbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// telegram is a messenger that sends replies with the telegram bot API.
+type telegram struct {
+	api *tgbotapi.BotAPI
+}
+
+// telegramAllowedUpdates are the kinds of telegram updates the bot asks for.
+var telegramAllowedUpdates = []string{
+	"message",
+	"edited_message",
+	"channel_post",
+	"edited_channel_post",
+	"callback_query",
+	"inline_query",
+	"my_chat_member",
+}
+
+func (t *telegram) send(ctx context.Context, r Reply) (int, error) {
+	if r.EditMessageID != 0 {
+		return t.edit(ctx, r)
+	}
+
+	msg := tgbotapi.NewMessage(r.ChatID, r.Text)
+	msg.ReplyToMessageID = r.ReplyToMessageID
+	if len(r.Keyboard) > 0 {
+		msg.ReplyMarkup = telegramKeyboard(r.Keyboard)
+	}
+
+	sent, err := t.api.Send(msg)
+	return sent.MessageID, telegramSendError(err)
+}
+
+// edit replaces the text and keyboard of the message r.EditMessageID.
+// Editing a message to what it already is counts as success.
+func (t *telegram) edit(ctx context.Context, r Reply) (int, error) {
+	edit := tgbotapi.NewEditMessageText(r.ChatID, r.EditMessageID, r.Text)
+	if len(r.Keyboard) > 0 {
+		keyboard := telegramKeyboard(r.Keyboard)
+		edit.ReplyMarkup = &keyboard
+	}
+
+	_, err := t.api.Send(edit)
+	if apiErr, ok := err.(tgbotapi.Error); ok && strings.Contains(apiErr.Message, "message is not modified") {
+		err = nil
+	}
+	return r.EditMessageID, telegramSendError(err)
+}
+
+func (t *telegram) answerCallback(ctx context.Context, callbackID string, text string) error {
+	_, err := t.api.AnswerCallbackQuery(tgbotapi.NewCallback(callbackID, text))
+	return err
+}
+
+func (t *telegram) isAdmin(ctx context.Context, chatID int64, userID int64) (bool, error) {
+	member, err := t.api.GetChatMember(tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: int(userID)})
+	if err != nil {
+		return false, err
+	}
+	return member.IsCreator() || member.IsAdministrator(), nil
+}
+
+// answerInline answers an inline query with articles. The answers
+// are personal, because their language depends on the user.
+func (t *telegram) answerInline(ctx context.Context, queryID string, answer InlineAnswer) error {
+	results := make([]interface{}, 0, len(answer.Results))
+	for i, result := range answer.Results {
+		article := tgbotapi.NewInlineQueryResultArticle(strconv.Itoa(i), result.Title, result.Text)
+		article.Description = result.Description
+		results = append(results, article)
+	}
+
+	_, err := t.api.AnswerInlineQuery(tgbotapi.InlineConfig{
+		InlineQueryID: queryID,
+		Results:       results,
+		CacheTime:     int(answer.CacheTime / time.Second),
+		IsPersonal:    true,
+	})
+	return err
+}
+
+func (t *telegram) setCommands(ctx context.Context, scope menuScope, language string, commands []menuCommand) error {
+	scopeJSON, err := json.Marshal(scope)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("scope", string(scopeJSON))
+	if language != "" {
+		params.Set("language_code", language)
+	}
+
+	if len(commands) == 0 {
+		_, err = t.api.MakeRequest("deleteMyCommands", params)
+		return err
+	}
+
+	commandsJSON, err := json.Marshal(commands)
+	if err != nil {
+		return err
+	}
+	params.Set("commands", string(commandsJSON))
+
+	_, err = t.api.MakeRequest("setMyCommands", params)
+	return err
+}
+
+// telegramSendError turns the errors of the telegram bot API that are worth
+// retrying into flood and temporary errors. Errors that did not come from
+// telegram, such as network errors and garbled responses, are temporary.
+func telegramSendError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	apiErr, ok := err.(tgbotapi.Error)
+	if !ok {
+		return &temporaryError{err}
+	}
+	if apiErr.RetryAfter > 0 {
+		return &floodError{time.Duration(apiErr.RetryAfter) * time.Second, err}
+	}
+	return err
+}
+
+// telegramKeyboard converts rows of buttons to a telegram inline keyboard.
+func telegramKeyboard(rows [][]Button) tgbotapi.InlineKeyboardMarkup {
+	keyboard := make([][]tgbotapi.InlineKeyboardButton, 0, len(rows))
+	for _, row := range rows {
+		keyboardRow := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
+		for _, b := range row {
+			keyboardRow = append(keyboardRow, tgbotapi.NewInlineKeyboardButtonData(b.Text, b.Data))
+		}
+		keyboard = append(keyboard, keyboardRow)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+}
+
+// telegramUpdate is a telegram update with the fields
+// that the telegram bot API package does not know.
+type telegramUpdate struct {
+	tgbotapi.Update
+	MyChatMember *telegramChatMemberUpdated `json:"my_chat_member"`
+}
+
+// telegramChatMemberUpdated tells that the status of a member of a chat changed.
+type telegramChatMemberUpdated struct {
+	Chat          *tgbotapi.Chat      `json:"chat"`
+	From          *tgbotapi.User      `json:"from"`
+	OldChatMember tgbotapi.ChatMember `json:"old_chat_member"`
+	NewChatMember tgbotapi.ChatMember `json:"new_chat_member"`
+}
+
+// fromTelegramUpdate converts a telegram update to an update
+// and classifies it by what happened.
+func fromTelegramUpdate(u telegramUpdate) Update {
+	converted := Update{ID: u.UpdateID, Kind: KindOther}
+
+	switch {
+	case u.Message != nil:
+		converted.Kind, converted.Message = KindMessage, fromTelegramMessage(u.Message)
+		if len(converted.Message.NewMembers) > 0 {
+			converted.Kind = KindMembersJoined
+		} else if converted.Message.LeftMember != nil {
+			converted.Kind = KindMemberLeft
+		}
+	case u.EditedMessage != nil:
+		converted.Kind, converted.Message = KindEditedMessage, fromTelegramMessage(u.EditedMessage)
+	case u.ChannelPost != nil:
+		converted.Kind, converted.Message = KindChannelPost, fromTelegramMessage(u.ChannelPost)
+	case u.EditedChannelPost != nil:
+		converted.Kind, converted.Message = KindEditedChannelPost, fromTelegramMessage(u.EditedChannelPost)
+	case u.CallbackQuery != nil:
+		converted.Kind = KindCallback
+		converted.Callback = &Callback{
+			ID:      u.CallbackQuery.ID,
+			Data:    u.CallbackQuery.Data,
+			Sender:  fromTelegramUser(u.CallbackQuery.From),
+			Message: fromTelegramMessage(u.CallbackQuery.Message),
+		}
+	case u.InlineQuery != nil:
+		converted.Kind = KindInlineQuery
+		converted.Inline = &Inline{
+			ID:     u.InlineQuery.ID,
+			Query:  u.InlineQuery.Query,
+			Sender: fromTelegramUser(u.InlineQuery.From),
+		}
+	case u.MyChatMember != nil:
+		converted.Kind = KindBotMember
+		converted.BotMember = &BotMember{
+			Chat:      fromTelegramChat(u.MyChatMember.Chat),
+			By:        fromTelegramUser(u.MyChatMember.From),
+			OldStatus: u.MyChatMember.OldChatMember.Status,
+			NewStatus: u.MyChatMember.NewChatMember.Status,
+		}
+	}
+
+	return converted
+}
+
+// fromTelegramMessage converts a telegram message to a message.
+// It returns nil if m is nil.
+func fromTelegramMessage(m *tgbotapi.Message) *Message {
+	if m == nil {
+		return nil
+	}
+
+	converted := &Message{
+		ID:         m.MessageID,
+		Text:       m.Text,
+		Sender:     fromTelegramUser(m.From),
+		Chat:       fromTelegramChat(m.Chat),
+		LeftMember: fromTelegramUser(m.LeftChatMember),
+	}
+	if m.NewChatMembers != nil {
+		for i := range *m.NewChatMembers {
+			converted.NewMembers = append(converted.NewMembers, *fromTelegramUser(&(*m.NewChatMembers)[i]))
+		}
+	}
+
+	return converted
+}
+
+// fromTelegramChat converts a telegram chat to a chat.
+func fromTelegramChat(c *tgbotapi.Chat) Chat {
+	if c == nil {
+		return Chat{}
+	}
+
+	return Chat{
+		ID:    c.ID,
+		Type:  c.Type,
+		Title: c.Title,
+	}
+}
+
+// fromTelegramUser converts a telegram user to a user.
+// It returns nil if u is nil.
+func fromTelegramUser(u *tgbotapi.User) *User {
+	if u == nil {
+		return nil
+	}
+
+	return &User{
+		ID:           int64(u.ID),
+		UserName:     u.UserName,
+		FirstName:    u.FirstName,
+		LanguageCode: u.LanguageCode,
+	}
+}
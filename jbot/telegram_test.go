@@ -0,0 +1,134 @@
+package jbot
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestFromTelegramUpdateMessage(t *testing.T) {
+	update := tgbotapi.Update{
+		UpdateID: 5,
+		Message: &tgbotapi.Message{
+			MessageID: 6,
+			Text:      "/wisdom ch1 2",
+			From:      &tgbotapi.User{ID: 7, UserName: "tester", LanguageCode: "fi"},
+			Chat:      &tgbotapi.Chat{ID: -8, Type: "group", Title: "Juhannus"},
+		},
+	}
+
+	u := fromTelegramUpdate(telegramUpdate{Update: update})
+	if u.ID != 5 || u.Kind != KindMessage || u.Message == nil || u.Callback != nil {
+		t.Fatalf("update was converted incorrectly: %+v", u)
+	}
+	if u.Message.ID != 6 || u.Message.Text != "/wisdom ch1 2" {
+		t.Errorf("message was converted incorrectly: %+v", u.Message)
+	}
+	if u.Message.Sender == nil || u.Message.Sender.ID != 7 || u.Message.Sender.LanguageCode != "fi" {
+		t.Errorf("sender was converted incorrectly: %+v", u.Message.Sender)
+	}
+	if u.Message.Chat != (Chat{ID: -8, Type: "group", Title: "Juhannus"}) {
+		t.Errorf("chat was converted incorrectly: %+v", u.Message.Chat)
+	}
+}
+
+func TestFromTelegramUpdateCallback(t *testing.T) {
+	update := tgbotapi.Update{
+		UpdateID: 5,
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "abc",
+			Data:    "♒",
+			From:    &tgbotapi.User{ID: 7},
+			Message: &tgbotapi.Message{MessageID: 6, Chat: &tgbotapi.Chat{ID: -8}},
+		},
+	}
+
+	u := fromTelegramUpdate(telegramUpdate{Update: update})
+	if u.Kind != KindCallback || u.Message != nil || u.Callback == nil {
+		t.Fatalf("update was converted incorrectly: %+v", u)
+	}
+	if u.Callback.ID != "abc" || u.Callback.Data != "♒" || u.Callback.Sender.ID != 7 {
+		t.Errorf("callback was converted incorrectly: %+v", u.Callback)
+	}
+	if u.ChatID() != -8 {
+		t.Errorf("expected chat ID -8, got %v", u.ChatID())
+	}
+}
+
+func TestFromTelegramUpdateKinds(t *testing.T) {
+	tests := []struct {
+		json string
+		kind UpdateKind
+	}{
+		{`{"update_id": 1, "edited_message": {"message_id": 2, "text": "/decide a b", "chat": {"id": 3}}}`, KindEditedMessage},
+		{`{"update_id": 1, "channel_post": {"message_id": 2, "text": "news", "chat": {"id": -3, "type": "channel"}}}`, KindChannelPost},
+		{`{"update_id": 1, "edited_channel_post": {"message_id": 2, "text": "news", "chat": {"id": -3, "type": "channel"}}}`, KindEditedChannelPost},
+		{`{"update_id": 1, "message": {"message_id": 2, "chat": {"id": -3}, "new_chat_members": [{"id": 4, "first_name": "Kalle"}]}}`, KindMembersJoined},
+		{`{"update_id": 1, "message": {"message_id": 2, "chat": {"id": -3}, "left_chat_member": {"id": 4}}}`, KindMemberLeft},
+		{`{"update_id": 1, "my_chat_member": {"chat": {"id": -3, "type": "group"}, "from": {"id": 4}, "old_chat_member": {"status": "left"}, "new_chat_member": {"status": "member"}}}`, KindBotMember},
+		{`{"update_id": 1, "inline_query": {"id": "5", "from": {"id": 4}, "query": "decide a b", "offset": ""}}`, KindInlineQuery},
+		{`{"update_id": 1, "poll": {"id": "5"}}`, KindOther},
+	}
+
+	for _, test := range tests {
+		var update telegramUpdate
+		if err := json.Unmarshal([]byte(test.json), &update); err != nil {
+			t.Fatal(err)
+		}
+		u := fromTelegramUpdate(update)
+		if u.Kind != test.kind {
+			t.Errorf("%v: expected kind %v, got %v", test.json, test.kind, u.Kind)
+		}
+		if test.kind != KindOther && u.ChatID() == 0 {
+			t.Errorf("%v: the chat was lost", test.json)
+		}
+	}
+
+	var update telegramUpdate
+	json.Unmarshal([]byte(tests[3].json), &update)
+	if u := fromTelegramUpdate(update); len(u.Message.NewMembers) != 1 || u.Message.NewMembers[0].FirstName != "Kalle" {
+		t.Errorf("new members were converted incorrectly: %+v", u.Message)
+	}
+
+	update = telegramUpdate{}
+	json.Unmarshal([]byte(tests[5].json), &update)
+	member := fromTelegramUpdate(update).BotMember
+	if member == nil || member.OldStatus != "left" || member.NewStatus != "member" || member.By.ID != 4 {
+		t.Errorf("bot membership was converted incorrectly: %+v", member)
+	}
+}
+
+func TestTelegramKeyboard(t *testing.T) {
+	keyboard := telegramKeyboard(getSignKeyboard())
+
+	if len(keyboard.InlineKeyboard) != 3 {
+		t.Fatalf("expected 3 rows, got %v", len(keyboard.InlineKeyboard))
+	}
+	first := keyboard.InlineKeyboard[0][0]
+	if first.Text != "♒" || first.CallbackData == nil || *first.CallbackData != "♒" {
+		t.Errorf("button was converted incorrectly: %+v", first)
+	}
+}
+
+func TestTelegramSendError(t *testing.T) {
+	flood := tgbotapi.Error{Message: "Too Many Requests: retry after 3", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 3}}
+	if e, ok := telegramSendError(flood).(*floodError); !ok || e.retryAfter != 3*time.Second {
+		t.Errorf("a flood error was not recognised: %#v", telegramSendError(flood))
+	}
+
+	if _, ok := telegramSendError(errors.New("connection reset")).(*temporaryError); !ok {
+		t.Error("a network error was not temporary")
+	}
+
+	badRequest := tgbotapi.Error{Message: "Bad Request: chat not found"}
+	if telegramSendError(badRequest) != badRequest {
+		t.Error("a bad request was retried")
+	}
+
+	if telegramSendError(nil) != nil {
+		t.Error("success became an error")
+	}
+}
@@ -0,0 +1,263 @@
+package jbot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	gjson "github.com/tidwall/gjson"
+)
+
+// toggles is a feature that lets chat administrators
+// enable and disable features in their own chat.
+// It owns the commands "/features", "/enable feature" and "/disable feature".
+type toggles struct {
+	listWords    []string
+	enableWords  []string
+	disableWords []string
+}
+
+func (t *toggles) String() string {
+	return "toggles"
+}
+
+func (t *toggles) Init(bot *Bot) error {
+
+	if !connected(bot.database) {
+		return errors.New("no database connection")
+	}
+
+	var tableExists bool
+	err := bot.database.QueryRow("SELECT EXISTS (SELECT * FROM disabled_feature)").Scan(&tableExists)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	t.listWords = configuredAliases(bot.cfg.Features, "toggles.list", "/features")
+	t.enableWords = configuredAliases(bot.cfg.Features, "toggles.enable", "/enable")
+	t.disableWords = configuredAliases(bot.cfg.Features, "toggles.disable", "/disable")
+
+	bot.toggles = newFeatureToggles(bot.database, bot.Log(context.Background()))
+	return nil
+}
+
+// Healthy returns an error if the database connection is lost.
+func (t *toggles) Healthy(bot *Bot) error {
+	return databaseHealthy(bot.database)
+}
+
+func (t *toggles) Describe() Description {
+	examples := append([]string{}, t.listWords...)
+	for _, word := range t.enableWords {
+		examples = append(examples, word+" <feature>")
+	}
+	for _, word := range t.disableWords {
+		examples = append(examples, word+" <feature>")
+	}
+	return Description{Examples: examples}
+}
+
+func (t *toggles) Commands() []string {
+	commands := append([]string{}, t.listWords...)
+	commands = append(commands, t.enableWords...)
+	return append(commands, t.disableWords...)
+}
+
+func (t *toggles) Triggers(u Update) bool {
+	return isCommand(u, t.Commands())
+}
+
+func (t *toggles) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	if u.Command == nil {
+		return false, nil
+	}
+
+	chatID := u.Message.Chat.ID
+	args := u.Command.Args
+
+	text := ""
+	if isCommand(u, t.listWords) {
+		text = listFeatureToggles(ctx, bot, u)
+	} else if len(args) < 1 {
+		text = bot.Text(ctx, u, "toggles.which", t.listWords[0])
+	} else {
+		enable := isCommand(u, t.enableWords)
+
+		var err error
+		text, err = t.toggle(ctx, bot, u, strings.ToLower(args[0]), enable)
+		if err != nil {
+			return true, err
+		}
+	}
+
+	_, err := bot.Send(ctx, Reply{ChatID: chatID, Text: text})
+	return true, err
+}
+
+// toggle enables or disables the feature called name in the chat of u,
+// if the sender of u is allowed to do that, and returns the reply to send.
+func (t *toggles) toggle(ctx context.Context, bot *Bot, u Update, name string, enable bool) (string, error) {
+
+	if name == t.String() || !featureRunning(bot, name) {
+		return bot.Text(ctx, u, "toggles.unknown", name, t.listWords[0]), nil
+	}
+
+	allowed, err := canChangeFeatures(ctx, bot, u.Message)
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return bot.Text(ctx, u, "toggles.admins"), nil
+	}
+
+	if err = bot.toggles.set(ctx, u.ChatID(), name, enable); err != nil {
+		return "", err
+	}
+
+	if enable {
+		return bot.Text(ctx, u, "toggles.enabled", name), nil
+	}
+	return bot.Text(ctx, u, "toggles.disabled", name), nil
+}
+
+// canChangeFeatures returns true if the sender of m may enable and disable
+// features in the chat of m. Anyone may do it in a private chat.
+func canChangeFeatures(ctx context.Context, bot *Bot, m *Message) (bool, error) {
+	if m.Chat.Type == "private" {
+		return true, nil
+	}
+	if m.Sender == nil {
+		return false, nil
+	}
+	return bot.IsAdmin(ctx, m.Chat.ID, m.Sender.ID)
+}
+
+// listFeatureToggles returns a list of the features that can be
+// toggled and whether they are enabled in the chat of u.
+func listFeatureToggles(ctx context.Context, bot *Bot, u Update) string {
+	names := []string{}
+	for _, feat := range bot.features {
+		if feat.String() != "toggles" {
+			names = append(names, feat.String())
+		}
+	}
+	sort.Strings(names)
+
+	lines := []string{bot.Text(ctx, u, "toggles.header")}
+	for _, name := range names {
+		if bot.toggles.enabled(ctx, u.ChatID(), name) {
+			lines = append(lines, "✅ "+name)
+		} else {
+			lines = append(lines, "❌ "+name)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// featureRunning returns true if a feature called name is running.
+func featureRunning(bot *Bot, name string) bool {
+	for _, feat := range bot.features {
+		if feat.String() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// configuredAliases returns the aliases at path in the features config,
+// or defaultAlias if there are none.
+func configuredAliases(features []byte, path string, defaultAlias string) []string {
+	jsonConfig := gjson.GetBytes(features, path)
+	if !jsonConfig.Exists() {
+		return []string{defaultAlias}
+	}
+
+	aliases := []string{}
+	for _, jsonWord := range jsonConfig.Array() {
+		aliases = append(aliases, jsonWord.String())
+	}
+	return aliases
+}
+
+// featureToggles keeps track of the features that are disabled in each chat.
+// The disabled features are stored in the database and cached in memory.
+type featureToggles struct {
+	database *sql.DB
+	logger   *Logger    // for the lookups without the logger of an update
+	disabled *chatCache // disabled features of the chats, map[string]bool
+
+	mu sync.Mutex // held while a toggle is changed
+}
+
+func newFeatureToggles(database *sql.DB, logger *Logger) *featureToggles {
+	t := &featureToggles{database: database, logger: logger}
+	t.disabled = newChatCache(cachedChats, t.load)
+	return t
+}
+
+// enabled returns true if the feature called name is enabled in chatID.
+// Features are enabled unless they have been disabled. If the toggles
+// of the chat can't be loaded, everything counts as enabled.
+func (t *featureToggles) enabled(ctx context.Context, chatID int64, name string) bool {
+	disabled, err := t.disabled.get(ctx, chatID)
+	if err != nil {
+		contextLogger(ctx, t.logger).Error("failed to load the feature toggles of the chat", "chat", chatID, "error", err)
+		return true
+	}
+	return !disabled.(map[string]bool)[name]
+}
+
+// set enables or disables the feature called name in chatID.
+func (t *featureToggles) set(ctx context.Context, chatID int64, name string, enable bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cached, err := t.disabled.get(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	if enable {
+		_, err = t.database.ExecContext(ctx, "DELETE FROM disabled_feature WHERE chat_id = $1 AND feature = $2", chatID, name)
+	} else {
+		_, err = t.database.ExecContext(ctx, "INSERT INTO disabled_feature (chat_id, feature) VALUES ($1, $2) ON CONFLICT DO NOTHING", chatID, name)
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	// the cached map may be in use, so it is replaced instead of changed
+	disabled := make(map[string]bool)
+	for feature, off := range cached.(map[string]bool) {
+		disabled[feature] = off
+	}
+	disabled[name] = !enable
+	t.disabled.put(chatID, disabled)
+	return nil
+}
+
+// load reads the disabled features of chatID from the database.
+func (t *featureToggles) load(ctx context.Context, chatID int64) (interface{}, error) {
+	rows, err := t.database.QueryContext(ctx, "SELECT feature FROM disabled_feature WHERE chat_id = $1", chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	disabled := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		disabled[name] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return disabled, nil
+}
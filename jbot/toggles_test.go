@@ -0,0 +1,97 @@
+package jbot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestFeatureTogglesEnabled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT feature FROM disabled_feature").WithArgs(42).WillReturnRows(sqlmock.NewRows([]string{"feature"}).AddRow("pingpong"))
+
+	toggles := newFeatureToggles(db, defaultLogger)
+	if toggles.enabled(context.Background(), 42, "pingpong") {
+		t.Error("disabled feature was enabled")
+	}
+	// the second lookup comes from the cache
+	if !toggles.enabled(context.Background(), 42, "decide") {
+		t.Error("feature that was not disabled was disabled")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestFeatureTogglesSet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT feature FROM disabled_feature").WithArgs(42).WillReturnRows(sqlmock.NewRows([]string{"feature"}))
+	mock.ExpectExec("^INSERT INTO disabled_feature").WithArgs(42, "horoscope").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^DELETE FROM disabled_feature").WithArgs(42, "horoscope").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	toggles := newFeatureToggles(db, defaultLogger)
+	if err := toggles.set(context.Background(), 42, "horoscope", false); err != nil {
+		t.Fatal(err)
+	}
+	if toggles.enabled(context.Background(), 42, "horoscope") {
+		t.Error("feature was enabled after disabling it")
+	}
+	if err := toggles.set(context.Background(), 42, "horoscope", true); err != nil {
+		t.Fatal(err)
+	}
+	if !toggles.enabled(context.Background(), 42, "horoscope") {
+		t.Error("feature was disabled after enabling it")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTogglesRequireAdminInGroups(t *testing.T) {
+	m := &recordingMessenger{admins: map[int64]bool{}}
+	bot := &Bot{messenger: m, features: []Feature{&fakeFeature{name: "pingpong"}}}
+	tg := &toggles{listWords: []string{"/features"}, enableWords: []string{"/enable"}, disableWords: []string{"/disable"}}
+
+	u := commandUpdate("/disable pingpong")
+	u.Message.Chat.Type = "group"
+
+	if !tg.Triggers(u) {
+		t.Fatal("toggles did not trigger on /disable")
+	}
+	if _, err := tg.Execute(context.Background(), bot, u); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.replies) != 1 || m.replies[0].Text != "Only chat administrators can change features" {
+		t.Errorf("a user that is not an administrator was not refused: %+v", m.replies)
+	}
+}
+
+func TestTogglesMentionListCommand(t *testing.T) {
+	m := &recordingMessenger{}
+	bot := &Bot{messenger: m, features: []Feature{&fakeFeature{name: "pingpong"}}}
+	tg := &toggles{listWords: []string{"/ominaisuudet"}, enableWords: []string{"/enable"}, disableWords: []string{"/disable"}}
+
+	for _, text := range []string{"/enable", "/enable sauna"} {
+		if _, err := tg.Execute(context.Background(), bot, commandUpdate(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(m.replies) != 2 || m.replies[0].Text != "Which feature? Try /ominaisuudet" || m.replies[1].Text != "Unknown feature sauna. Try /ominaisuudet" {
+		t.Errorf("the replies do not mention the configured command: %+v", m.replies)
+	}
+}
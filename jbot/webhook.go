@@ -0,0 +1,128 @@
+package jbot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	defaultWebhookListen = ":8080"
+
+	// secretTokenHeader carries the secret token in every webhook request
+	// once the token has been given to setWebhook.
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+)
+
+// WebhookConfig holds the configuration for receiving updates over a webhook.
+type WebhookConfig struct {
+	Enabled     bool   `json:"enabled"`     // if true, updates come from the webhook instead of long polling
+	URL         string `json:"url"`         // public https URL that telegram sends the updates to
+	Listen      string `json:"listen"`      // address of the local HTTP listener, e.g. ":8080"
+	Path        string `json:"path"`        // path of the local HTTP listener, defaults to the path of URL
+	SecretToken string `json:"secrettoken"` // if set, requests without this token are rejected
+}
+
+// serveWebhook registers the webhook with telegram and passes the updates it
+// receives to submit until ctx is done. The webhook is removed before returning.
+// The webhook is registered only once the HTTP listener is listening.
+func serveWebhook(ctx context.Context, botAPI *tgbotapi.BotAPI, cfg WebhookConfig, submit func(Update) bool, logger *Logger) error {
+
+	webhookURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+
+	path := cfg.Path
+	if path == "" {
+		path = webhookURL.Path
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	listen := cfg.Listen
+	if listen == "" {
+		listen = defaultWebhookListen
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, webhookHandler(cfg.SecretToken, submit))
+	server := &http.Server{Addr: listen, Handler: mux}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+
+	allowedUpdates, err := json.Marshal(telegramAllowedUpdates)
+	if err != nil {
+		server.Close()
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("url", webhookURL.String())
+	params.Set("allowed_updates", string(allowedUpdates))
+	if cfg.SecretToken != "" {
+		params.Set("secret_token", cfg.SecretToken)
+	}
+	if _, err = botAPI.MakeRequest("setWebhook", params); err != nil {
+		server.Close()
+		return err
+	}
+	logger.Info("receiving updates from webhook", "url", webhookURL, "listen", listen)
+
+	select {
+	case <-ctx.Done():
+	case err = <-serverErr:
+	}
+
+	if _, deleteErr := botAPI.MakeRequest("deleteWebhook", url.Values{}); deleteErr != nil {
+		logger.Error("failed to delete webhook", "error", deleteErr)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+
+	return err
+}
+
+// webhookHandler returns a handler that decodes updates from webhook
+// requests and passes them to submit. If secretToken is not empty,
+// requests must carry it in their secret token header.
+func webhookHandler(secretToken string, submit func(Update) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := r.Header.Get(secretTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secretToken)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		var update telegramUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		submit(fromTelegramUpdate(update))
+		w.WriteHeader(http.StatusOK)
+	})
+}
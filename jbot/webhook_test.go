@@ -0,0 +1,77 @@
+package jbot
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testWebhookBody = `{"update_id": 42, "message": {"message_id": 1, "text": "/decide a b", "chat": {"id": 7}}}`
+
+// webhookRequest sends body to handler with the given secret token
+// and returns the response status code.
+func webhookRequest(handler http.Handler, token, body string) int {
+	request := httptest.NewRequest(http.MethodPost, "/jbot", strings.NewReader(body))
+	if token != "" {
+		request.Header.Set(secretTokenHeader, token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestWebhookHandlerSubmitsUpdate(t *testing.T) {
+	var submitted []Update
+	handler := webhookHandler("s3cret", func(u Update) bool {
+		submitted = append(submitted, u)
+		return true
+	})
+
+	if code := webhookRequest(handler, "s3cret", testWebhookBody); code != http.StatusOK {
+		t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+	}
+	if len(submitted) != 1 || submitted[0].ID != 42 || submitted[0].ChatID() != 7 {
+		t.Fatalf("update was not submitted correctly: %v", submitted)
+	}
+}
+
+func TestWebhookHandlerRejectsWrongToken(t *testing.T) {
+	handler := webhookHandler("s3cret", func(u Update) bool {
+		t.Error("update with a wrong secret token was submitted")
+		return true
+	})
+
+	for _, token := range []string{"", "wrong", "s3cret2"} {
+		if code := webhookRequest(handler, token, testWebhookBody); code != http.StatusUnauthorized {
+			t.Errorf("token %q: expected status %v, got %v", token, http.StatusUnauthorized, code)
+		}
+	}
+}
+
+func TestWebhookHandlerRejectsBrokenBody(t *testing.T) {
+	handler := webhookHandler("", func(u Update) bool {
+		t.Error("broken update was submitted")
+		return true
+	})
+
+	if code := webhookRequest(handler, "", "{not json"); code != http.StatusBadRequest {
+		t.Errorf("expected status %v, got %v", http.StatusBadRequest, code)
+	}
+}
+
+func TestServeWebhookFailsWithoutListener(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	// the webhook is not registered, which would fail without a bot API
+	cfg := WebhookConfig{Enabled: true, URL: "https://example.com/hook", Listen: busy.Addr().String()}
+	if err := serveWebhook(context.Background(), nil, cfg, func(Update) bool { return true }, defaultLogger); err == nil {
+		t.Error("the webhook was served on an address that is in use")
+	}
+}
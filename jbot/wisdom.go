@@ -2,15 +2,20 @@
 package jbot
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	gjson "github.com/tidwall/gjson"
 )
 
+// wisdomInlineCacheTime is how long the answer to an inline
+// query for a particular line is cached.
+const wisdomInlineCacheTime = time.Hour
+
 type wisdom struct {
 	triggerWords []string
 }
@@ -19,7 +24,7 @@ func (w *wisdom) String() string {
 	return "wisdom"
 }
 
-func (w *wisdom) init(bot *jbot) error {
+func (w *wisdom) Init(bot *Bot) error {
 
 	if !connected(bot.database) {
 		return errors.New("no database connection")
@@ -46,58 +51,91 @@ func (w *wisdom) init(bot *jbot) error {
 	return nil
 }
 
-func (w *wisdom) triggers(u tgbotapi.Update) bool {
-	if u.Message == nil {
-		return false
+// Healthy returns an error if the database connection is lost.
+func (w *wisdom) Healthy(bot *Bot) error {
+	return databaseHealthy(bot.database)
+}
+
+func (w *wisdom) Describe() Description {
+	examples := []string{}
+	for _, word := range w.triggerWords {
+		examples = append(examples, word, word+" <chapter> <verse>")
 	}
+	return Description{Examples: examples}
+}
 
-	return stringHasAnyPrefix(u.Message.Text, w.triggerWords)
+func (w *wisdom) Commands() []string {
+	return w.triggerWords
 }
 
-func (w *wisdom) execute(bot *jbot, u tgbotapi.Update) error {
+func (w *wisdom) Triggers(u Update) bool {
+	return isCommand(u, w.triggerWords)
+}
+
+func (w *wisdom) Execute(ctx context.Context, bot *Bot, u Update) (bool, error) {
+	if u.Command == nil {
+		return false, nil
+	}
 
-	text, err := createBookResposeString(bot, u.Message.Text)
+	text, _, err := createBookResposeString(ctx, bot, u.Command.Args)
 	if err != nil {
-		return err
+		return true, err
 	}
-	msg := tgbotapi.NewMessage(u.Message.Chat.ID, text)
-	bot.botAPI.Send(msg)
+	_, err = bot.Send(ctx, Reply{ChatID: u.Message.Chat.ID, Text: text})
+	return true, err
+}
 
-	return nil
+// AnswerInline offers the line as a result. A line that was asked
+// for is cached, a random one changes with every query.
+func (w *wisdom) AnswerInline(ctx context.Context, bot *Bot, u Update) (InlineAnswer, error) {
+	text, random, err := createBookResposeString(ctx, bot, u.Command.Args)
+	if err != nil {
+		return InlineAnswer{}, err
+	}
+
+	answer := InlineAnswer{Results: []InlineResult{{
+		Title:       bot.Text(ctx, u, "wisdom.inline.title"),
+		Description: text,
+		Text:        text,
+	}}}
+	if !random {
+		answer.CacheTime = wisdomInlineCacheTime
+	}
+	return answer, nil
 }
 
 // createBookResposeString creates a string containing the appropriate
-// response to a bookline related command.
-func createBookResposeString(bot *jbot, message string) (string, error) {
-	words := strings.Split(message, " ")
-	if len(words) >= 3 {
+// response to a bookline related command. If args are a chapter and
+// a verse, that line is chosen, otherwise a random one.
+// random is true if the line was chosen randomly.
+func createBookResposeString(ctx context.Context, bot *Bot, args []string) (response string, random bool, err error) {
+	if len(args) >= 2 {
 		// try a specific line
-		line, _ := getBookLine(bot.database, strings.Replace(strings.ToLower(words[1]), ".", "", -1), words[2])
+		line, _ := getBookLine(ctx, bot.database, strings.Replace(strings.ToLower(args[0]), ".", "", -1), args[1])
 		if line != "" {
-			return line, nil
+			return line, false, nil
 		}
 	}
 
-	response := ""
-	response, err := getRandomBookLine(bot.database)
+	response, err = getRandomBookLine(ctx, bot.database)
 	if err != nil {
-		return "", fmt.Errorf("database error: %v", err)
+		return "", true, fmt.Errorf("database error: %v", err)
 	}
-	return response, nil
+	return response, true, nil
 }
 
 // getBookLine fetches a particular bookline from a database.
-func getBookLine(database *sql.DB, chapter string, verse string) (string, error) {
+func getBookLine(ctx context.Context, database *sql.DB, chapter string, verse string) (string, error) {
 	var text string
-	err := database.QueryRow("SELECT text FROM book WHERE chapter = $1 and verse = $2", chapter, verse).Scan(&text)
+	err := database.QueryRowContext(ctx, "SELECT text FROM book WHERE chapter = $1 and verse = $2", chapter, verse).Scan(&text)
 	return text, err
 }
 
 // getBookLine fetches and formats a random bookline from a database.
-func getRandomBookLine(database *sql.DB) (string, error) {
+func getRandomBookLine(ctx context.Context, database *sql.DB) (string, error) {
 
 	var chapter, verse, text string
-	rows, err := database.Query("SELECT chapter, verse, text FROM book ORDER BY RANDOM() LIMIT 1")
+	rows, err := database.QueryContext(ctx, "SELECT chapter, verse, text FROM book ORDER BY RANDOM() LIMIT 1")
 	if err != nil {
 		return "", err
 	}
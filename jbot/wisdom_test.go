@@ -1,6 +1,7 @@
 package jbot
 
 import (
+	"context"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -16,7 +17,7 @@ func TestGetBookLine(t *testing.T) {
 
 	mock.ExpectQuery("^SELECT .*").WithArgs("test", "1:1").WillReturnRows(sqlmock.NewRows([]string{"text"}).AddRow("contents of mock database"))
 
-	contents, err := getBookLine(db, "test", "1:1")
+	contents, err := getBookLine(context.Background(), db, "test", "1:1")
 	if err != nil {
 		t.Errorf("error was not expected: %s", err)
 	}
@@ -41,7 +42,7 @@ func TestGetRandomBookLine(t *testing.T) {
 
 	mock.ExpectQuery("^SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"chapter", "verse", "text"}).AddRow("test", "123", "lorem ipsum"))
 
-	contents, err := getRandomBookLine(db)
+	contents, err := getRandomBookLine(context.Background(), db)
 	if err != nil {
 		t.Errorf("error was not expected: %s", err)
 	}
@@ -0,0 +1,106 @@
+package jbot
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Worker can be implemented by features that work in the background,
+// such as refreshing data from the web. Work is called in its own
+// goroutine when the feature starts running and must return when ctx
+// is done, which happens when the bot stops, when the config is
+// reloaded and when the feature goes offline.
+type Worker interface {
+	Work(ctx context.Context, bot *Bot)
+}
+
+// workerOf returns the Worker of feat, looking through middlewares.
+func workerOf(feat Feature) (Worker, bool) {
+	for _, f := range featureChain(feat) {
+		if worker, ok := f.(Worker); ok {
+			return worker, true
+		}
+	}
+	return nil, false
+}
+
+// workers runs the Workers of the current Bot. The zero value
+// is ready to use and starts nothing until start is called.
+type workers struct {
+	mu     sync.Mutex
+	ctx    context.Context    // context of Run, nil until start is called
+	cancel context.CancelFunc // stops the workers of the current Bot
+	wg     sync.WaitGroup
+}
+
+// start starts the workers of bot. They are stopped when ctx is done.
+func (w *workers) start(ctx context.Context, bot *Bot) {
+	w.mu.Lock()
+	w.ctx = ctx
+	w.mu.Unlock()
+
+	w.switchTo(bot)
+}
+
+// switchTo stops the running workers and starts the ones of bot,
+// if start has been called.
+func (w *workers) switchTo(bot *Bot) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel != nil {
+		w.cancel()
+	}
+	if w.ctx == nil {
+		return
+	}
+
+	var ctx context.Context
+	ctx, w.cancel = context.WithCancel(w.ctx)
+	for _, feat := range bot.features {
+		if worker, ok := workerOf(feat); ok {
+			w.wg.Add(1)
+			go w.run(ctx, bot, feat.String(), worker)
+		}
+	}
+}
+
+// run runs worker until it returns. A panic in worker is logged and
+// stops only that worker.
+func (w *workers) run(ctx context.Context, bot *Bot, name string, worker Worker) {
+	defer w.wg.Done()
+	logger := bot.Log(ctx).With("feature", name)
+	defer func() {
+		if r := recover(); r != nil {
+			bot.stats.featurePanicked(name)
+			logger.Error("background work panicked", "error", r, "stack", debug.Stack())
+		}
+	}()
+
+	worker.Work(withLogger(ctx, logger), bot)
+}
+
+// stop stops the workers and waits at most timeout for them to return.
+// It returns false if they did not return in time.
+func (w *workers) stop(timeout time.Duration) bool {
+	w.mu.Lock()
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
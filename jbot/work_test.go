@@ -0,0 +1,73 @@
+package jbot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// workingFeature is a feature that works in the background
+// until it is stopped.
+type workingFeature struct {
+	fakeFeature
+	started chan struct{}
+	stopped chan struct{}
+	panics  bool
+}
+
+func newWorkingFeature(name string) *workingFeature {
+	return &workingFeature{
+		fakeFeature: fakeFeature{name: name},
+		started:     make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+func (f *workingFeature) Work(ctx context.Context, bot *Bot) {
+	close(f.started)
+	if f.panics {
+		close(f.stopped)
+		panic("work failed")
+	}
+	<-ctx.Done()
+	close(f.stopped)
+}
+
+// waitFor fails t if c isn't closed in a second.
+func waitFor(t *testing.T, c chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-c:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the worker to %v", what)
+	}
+}
+
+func TestWorkersFollowTheCurrentBot(t *testing.T) {
+	var w workers
+	old := newWorkingFeature("old")
+	new := newWorkingFeature("new")
+	panicking := newWorkingFeature("panicking")
+	panicking.panics = true
+
+	// nothing runs before start
+	w.switchTo(&Bot{features: []Feature{old}})
+	select {
+	case <-old.started:
+		t.Fatal("worker started before the runner")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	w.start(context.Background(), &Bot{features: []Feature{old, &fakeFeature{name: "idle"}}})
+	waitFor(t, old.started, "start")
+
+	w.switchTo(&Bot{features: []Feature{new, panicking}})
+	waitFor(t, old.stopped, "stop")
+	waitFor(t, new.started, "start")
+	waitFor(t, panicking.stopped, "panic")
+
+	if !w.stop(time.Second) {
+		t.Fatal("workers did not stop")
+	}
+	waitFor(t, new.stopped, "stop")
+}
@@ -1,14 +1,24 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"os"
 
 	"github.com/rasm47/juhannusbot/jbot"
 )
 
 func main() {
 
-	err := jbot.Start()
+	console := flag.Bool("console", false, "chat with the bot in the terminal instead of telegram")
+	flag.Parse()
+
+	var err error
+	if *console {
+		err = jbot.StartConsole(os.Stdin, os.Stdout)
+	} else {
+		err = jbot.Start()
+	}
 	if err != nil {
 		log.Printf("Closing bot due to error: %v", err)
 	}